@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ernoaapa/kubectl-warp/pkg/kubectl"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var attachCmd = &cobra.Command{
+	Use:   "attach NAME",
+	Short: "Attach to the running warp Pod",
+	Long: `Reconnect to the warp Pod what were left running, e.g. with --detach flag or
+because the connection were lost. Resumes the file sync and attaches to the command.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfig(cmd, opt.Profile); err != nil {
+			return err
+		}
+
+		stopChannel, stopInterrupt := interruptChannel()
+		defer stopInterrupt()
+
+		var (
+			name          = args[0]
+			stdin         io.Reader
+			stdout        = os.Stdout
+			stderr        = os.Stderr
+			containerName = "exec" // TODO
+		)
+
+		mappings, policy, err := validateSyncOptions()
+		if err != nil {
+			return err
+		}
+
+		ports, err := portMappings()
+		if err != nil {
+			return err
+		}
+
+		ns, restConfig, c, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		pod, err := c.GetPod(ns, name)
+		if err != nil {
+			return err
+		}
+
+		mappings, err = podMappings(cmd.Flags(), pod, mappings)
+		if err != nil {
+			return err
+		}
+
+		if _, err := c.WaitForPod(ns, name, kubectl.ContainerRunning(containerName)); err != nil {
+			if err == kubectl.ErrPodCompleted {
+				fmt.Fprintf(stderr, "Pod %s execution container were already completed. Print logs out\n", name)
+				return printCompleted(c, ns, name, containerName, stdout)
+			}
+			return err
+		}
+
+		container, err := kubectl.FindContainer(pod, containerName)
+		if err != nil {
+			return err
+		}
+		if container.Stdin {
+			stdin = os.Stdin
+		}
+
+		// The private key of the previous session is gone, so authorize new key for this session
+		var keys kubectl.SSHKeys
+		if kubectl.GetTransport(pod) == kubectl.SSHTransport {
+			keys, err = c.GetSSHKeys(ns, name)
+			if err != nil {
+				return err
+			}
+			keys.PrivateKey, keys.PublicKey, err = clientKeys(restConfig)
+			if err != nil {
+				return err
+			}
+			if err := c.UpdateAuthorizedKey(ns, name, keys.PublicKey); err != nil {
+				return errors.Wrap(err, "Cannot authorize the session key")
+			}
+		}
+
+		defer closeSession(c, ns, name, opt.Detach, stderr)
+
+		stopHeartbeat := heartbeat(c, ns, name, stderr)
+		defer stopHeartbeat()
+
+		s, cleanup, err := connect(c, restConfig, ns, name, pod, keys, ports, stopChannel, stderr)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		if s, ok := s.(*sshSyncer); ok {
+			fmt.Fprintln(stderr, "Wait the Pod to accept the session key")
+			if err := s.waitAuthorized(opt.PodRunningTimeout); err != nil {
+				return err
+			}
+		}
+
+		go backgroundSync(c, ns, name, s, mappings, policy, stopChannel, stderr)
+
+		return attach(c, ns, name, containerName, s, mappings, policy, stdin, stdout, stderr, container.TTY)
+	},
+	// We handle errors at root.go
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(attachCmd)
+	addSyncFlags(attachCmd.Flags())
+}
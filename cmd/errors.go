@@ -0,0 +1,22 @@
+package cmd
+
+import "fmt"
+
+// Exit codes for the failures of warp itself, so they can be told apart from the command exit codes
+const (
+	// exitCodeWarpFailure is used when warp fails, e.g. cannot create or connect to the Pod
+	exitCodeWarpFailure = 125
+	// exitCodeTimeout is used when one of the --pod-running-timeout, --sync-timeout or --timeout expires
+	exitCodeTimeout = 124
+	// exitCodeInterrupted is used when user interrupts warp with ctrl+c
+	exitCodeInterrupted = 130
+)
+
+// exitError is returned when the command in the exec container exits with non-zero exit code
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("command terminated with exit code %d", e.code)
+}
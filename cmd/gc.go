@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ernoaapa/kubectl-warp/pkg/kubectl"
+	"github.com/spf13/cobra"
+)
+
+type gcOptions struct {
+	AllNamespaces    bool
+	OlderThan        time.Duration
+	HeartbeatTimeout time.Duration
+	VolumeTTL        time.Duration
+	DryRun           bool
+}
+
+var gcOpt = gcOptions{}
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove the stale warp Pods and Secrets",
+	Long: `Remove the warp Pods what are completed, older than --older-than or which nobody
+is connected to anymore, e.g. because warp were killed. Detached Pods are removed only
+when they are older than --older-than. Removes also the SSH Secrets without Pod and restores
+the Deployments swapped to the removed Pods, and removes the --persistent and --cache volumes
+what are not used in --volume-ttl.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ns, _, c, err := newClient()
+		if err != nil {
+			return err
+		}
+		if gcOpt.AllNamespaces {
+			ns = ""
+		}
+
+		pods, err := c.ListPods(ns)
+		if err != nil {
+			return err
+		}
+
+		action, restoreAction := "Delete", "Restore"
+		if gcOpt.DryRun {
+			action, restoreAction = "Would delete", "Would restore"
+		}
+
+		now := time.Now()
+		alive := map[string]bool{}
+		usedVolumes := map[string]bool{}
+		for _, pod := range pods {
+			reason := kubectl.StaleReason(pod, now, gcOpt.OlderThan, gcOpt.HeartbeatTimeout)
+			if reason == "" {
+				alive[pod.Namespace+"/"+pod.Name] = true
+				for _, claim := range kubectl.UsedClaims(pod) {
+					usedVolumes[pod.Namespace+"/"+claim] = true
+				}
+				continue
+			}
+
+			fmt.Fprintf(os.Stdout, "%s Pod %s/%s: %s\n", action, pod.Namespace, pod.Name, reason)
+			if !gcOpt.DryRun {
+				if err := c.DeletePod(pod.Namespace, pod.Name); err != nil {
+					return err
+				}
+			}
+		}
+
+		deployments, err := c.ListSwappedDeployments(ns)
+		if err != nil {
+			return err
+		}
+		for _, deployment := range deployments {
+			if alive[deployment.Namespace+"/"+deployment.Annotations[kubectl.SwappedPodAnnotation]] {
+				continue
+			}
+
+			fmt.Fprintf(os.Stdout, "%s Deployment %s/%s: the swapped Pod is gone\n", restoreAction, deployment.Namespace, deployment.Name)
+			if !gcOpt.DryRun {
+				if _, err := c.RestoreDeployment(deployment.Namespace, deployment.Name, deployment.Annotations[kubectl.SwappedPodAnnotation]); err != nil {
+					return err
+				}
+			}
+		}
+
+		volumes, err := c.ListVolumes(ns)
+		if err != nil {
+			return err
+		}
+		for _, volume := range volumes {
+			if usedVolumes[volume.Namespace+"/"+volume.Name] {
+				continue
+			}
+			reason := kubectl.VolumeStaleReason(volume, now, gcOpt.VolumeTTL)
+			if reason == "" {
+				continue
+			}
+
+			fmt.Fprintf(os.Stdout, "%s PersistentVolumeClaim %s/%s: %s\n", action, volume.Namespace, volume.Name, reason)
+			if !gcOpt.DryRun {
+				if err := c.DeleteVolume(volume.Namespace, volume.Name); err != nil {
+					return err
+				}
+			}
+		}
+
+		secrets, err := c.ListSSHSecrets(ns)
+		if err != nil {
+			return err
+		}
+		for _, secret := range secrets {
+			// Secrets owned by the deleted Pods get removed by the Kubernetes garbage collector
+			if alive[secret.Namespace+"/"+secret.Name] || len(secret.OwnerReferences) > 0 {
+				continue
+			}
+
+			fmt.Fprintf(os.Stdout, "%s Secret %s/%s: no Pod\n", action, secret.Namespace, secret.Name)
+			if !gcOpt.DryRun {
+				if err := c.DeleteSSHSecret(secret.Namespace, secret.Name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+	// We handle errors at root.go
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+
+	gcCmd.Flags().BoolVarP(&gcOpt.AllNamespaces, "all-namespaces", "A", gcOpt.AllNamespaces, "Remove the stale warp Pods across all namespaces")
+	gcCmd.Flags().DurationVar(&gcOpt.OlderThan, "older-than", 24*time.Hour, "Remove the Pods older than this, 0 means no limit")
+	gcCmd.Flags().DurationVar(&gcOpt.HeartbeatTimeout, "heartbeat-timeout", 5*time.Minute, "Remove the Pods what are not detached and have no heartbeat in this time, 0 disables")
+	gcCmd.Flags().DurationVar(&gcOpt.VolumeTTL, "volume-ttl", 7*24*time.Hour, "Remove the --persistent and --cache volumes what are not used in this time, 0 keeps them forever")
+	gcCmd.Flags().BoolVar(&gcOpt.DryRun, "dry-run", gcOpt.DryRun, "Only print what would be removed")
+}
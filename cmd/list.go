@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ernoaapa/kubectl-warp/pkg/kubectl"
+	"github.com/spf13/cobra"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/yaml"
+)
+
+type listOptions struct {
+	AllNamespaces bool
+	Output        string
+}
+
+var listOpt = listOptions{}
+
+// sessionInfo is the information about the warp Pod what is printed in the list
+type sessionInfo struct {
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Owner     string    `json:"owner"`
+	Hostname  string    `json:"hostname"`
+	SourceDir string    `json:"sourceDir"`
+	Image     string    `json:"image"`
+	StartTime time.Time `json:"startTime"`
+}
+
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the warp Pods",
+	Long:    `List the warp Pods and who started them, where and when.`,
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ns, _, c, err := newClient()
+		if err != nil {
+			return err
+		}
+		if listOpt.AllNamespaces {
+			ns = ""
+		}
+
+		pods, err := c.ListPods(ns)
+		if err != nil {
+			return err
+		}
+
+		sessions := make([]sessionInfo, len(pods))
+		for i, pod := range pods {
+			sessions[i] = newSessionInfo(pod)
+		}
+
+		return printSessions(os.Stdout, sessions, listOpt.Output, listOpt.AllNamespaces)
+	},
+	// We handle errors at root.go
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().BoolVarP(&listOpt.AllNamespaces, "all-namespaces", "A", listOpt.AllNamespaces, "List the warp Pods across all namespaces")
+	listCmd.Flags().StringVarP(&listOpt.Output, "output", "o", "table", "Output format, one of table, json or yaml")
+}
+
+func newSessionInfo(pod apiv1.Pod) sessionInfo {
+	startTime := pod.CreationTimestamp.Time
+	if t, err := time.Parse(time.RFC3339, pod.Annotations[kubectl.StartTimeAnnotation]); err == nil {
+		startTime = t
+	}
+
+	status := string(pod.Status.Phase)
+	if pod.DeletionTimestamp != nil {
+		status = "Terminating"
+	}
+
+	return sessionInfo{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Status:    status,
+		Owner:     pod.Annotations[kubectl.OwnerAnnotation],
+		Hostname:  pod.Annotations[kubectl.HostnameAnnotation],
+		SourceDir: pod.Annotations[kubectl.SourceDirAnnotation],
+		Image:     pod.Annotations[kubectl.ImageAnnotation],
+		StartTime: startTime,
+	}
+}
+
+func printSessions(out io.Writer, sessions []sessionInfo, format string, allNamespaces bool) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(sessions, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+
+	case "yaml":
+		data, err := yaml.Marshal(sessions)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(data)
+		return err
+
+	case "table", "":
+		w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+		if allNamespaces {
+			fmt.Fprint(w, "NAMESPACE\t")
+		}
+		fmt.Fprintln(w, "NAME\tSTATUS\tOWNER\tHOSTNAME\tSOURCE\tIMAGE\tAGE")
+		for _, s := range sessions {
+			if allNamespaces {
+				fmt.Fprintf(w, "%s\t", s.Namespace)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.Status, s.Owner, s.Hostname, s.SourceDir, s.Image, duration.HumanDuration(time.Since(s.StartTime)))
+		}
+		return w.Flush()
+	}
+
+	return fmt.Errorf("Invalid output format %q, must be one of table, json or yaml", format)
+}
@@ -22,3 +22,11 @@ func logOutput(client *kubectl.Client, namespace, pod, containerName string, std
 	_, err = io.Copy(stdout, readCloser)
 	return err
 }
+
+// printCompleted prints the completed container output and returns exitError if the command failed
+func printCompleted(client *kubectl.Client, namespace, pod, containerName string, stdout io.Writer) error {
+	if err := logOutput(client, namespace, pod, containerName, stdout); err != nil {
+		return err
+	}
+	return commandResult(client, namespace, pod, containerName)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ernoaapa/kubectl-warp/pkg/kubectl"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore DEPLOYMENT",
+	Short: "Restore the Deployment swapped with --swap",
+	Long: `Scale the Deployment swapped with --swap back to the original replica count and
+delete the warp Pod what replaced it. Use it when warp were killed or the Pod were detached.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := strings.TrimPrefix(args[0], "deployment/")
+
+		ns, _, c, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		podName, err := c.RestoreDeployment(ns, name, "")
+		if err != nil {
+			return errors.Wrapf(err, "Cannot restore the Deployment %s", name)
+		}
+		if podName == "" {
+			fmt.Fprintf(os.Stdout, "Deployment %s is not swapped\n", name)
+			return nil
+		}
+
+		fmt.Fprintf(os.Stdout, "Deployment %s restored\n", name)
+		if pod, err := c.GetPod(ns, podName); err == nil && pod.Labels[kubectl.ManagedByLabel] == kubectl.ManagedByWarp {
+			fmt.Fprintf(os.Stdout, "Delete Pod %s\n", podName)
+			return c.DeletePod(ns, podName)
+		}
+		return nil
+	},
+	// We handle errors at root.go
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+}
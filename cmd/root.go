@@ -14,101 +14,279 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
-	"os/signal"
-	"strings"
+	"path"
+	"path/filepath"
 	"time"
 
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 
 	"github.com/ernoaapa/kubectl-warp/pkg/cert"
+	"github.com/ernoaapa/kubectl-warp/pkg/config"
 	"github.com/ernoaapa/kubectl-warp/pkg/kubectl"
-	"github.com/ernoaapa/kubectl-warp/pkg/sync"
 	"github.com/ernoaapa/kubectl-warp/pkg/utils"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
 )
 
 type runOptions struct {
-	Image     string
-	Stdin     bool
-	TTY       bool
-	RsyncArgs string
-	Includes  []string
-	Excludes  []string
+	Image              string
+	Stdin              bool
+	TTY                bool
+	RsyncArgs          string
+	Includes           []string
+	Excludes           []string
 	ServiceAccountName string
-	NodeSelector map[string]string
+	NodeSelector       map[string]string
+	Tolerations        []string
+	NodeAffinity       []string
+	NodeName           string
+	PriorityClass      string
+	Poll               bool
+	Syncer             string
+	GitIgnore          bool
+	Pull               []string
+	PullInterval       time.Duration
+	Conflict           string
+	Profile            string
+	Detach             bool
+	ActiveDeadline     time.Duration
+	SecurityProfile    string
+	Transport          string
+	SyncImage          string
+	ImagePullPolicy    string
+	ImagePullSecrets   []string
+	KeyType            string
+	KeyCache           bool
+	KeyMaxAge          time.Duration
+	PodRunningTimeout  time.Duration
+	SyncTimeout        time.Duration
+	Timeout            time.Duration
+	Ports              []string
+	Env                []string
+	EnvFrom            []string
+	EnvFiles           []string
+	Requests           map[string]string
+	Limits             map[string]string
+	Overrides          string
+	PodTemplate        string
+	From               string
+	Container          string
+	Swap               bool
+	Persistent         bool
+	Caches             []string
+	StorageClass       string
+	VolumeSize         string
+	WorkDir            string
+	SyncPaths          []string
 }
 
 var configFlags = genericclioptions.NewConfigFlags()
 var opt = runOptions{}
-var workDir = "/work-dir"
 var devNull = utils.DevNull(0)
 
 var rootCmd = &cobra.Command{
-	Use:   "warp",
+	Use:   "warp NAME [flags] -- COMMAND [args...]",
 	Short: "Transfer local files and run command in container",
 	Long: `Start Pod and syncs local files to Pod and executes command
 along with the synchronized files.`,
-	RunE: func(_ *cobra.Command, args []string) error {
-		stopChannel := make(chan struct{}, 1)
-		// ctrl+c signal
-		signals := make(chan os.Signal, 1)
-		signal.Notify(signals, os.Interrupt)
-		defer signal.Stop(signals)
-
-		go func() {
-			<-signals
-			close(stopChannel)
-		}()
+	Args: cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		if err := loadConfig(cmd, opt.Profile); err != nil {
+			return err
+		}
+		if opt.Image == "" && opt.From == "" {
+			return errors.New("--image or --from is required, set it with the flag or in the " + config.FileName + " file")
+		}
+
+		stopChannel, stopInterrupt := interruptChannel()
+		defer stopInterrupt()
+
 		if len(args) < 1 {
 			return errors.New("NAME is required for warp")
 		}
 		var (
 			name          = args[0]
-			cmd           = args[1:]
+			command       = args[1:]
 			stdin         = os.Stdin
 			stdout        = os.Stdout
 			stderr        = os.Stderr
 			containerName = "exec" // TODO
 		)
 
-		privateKey, publicKey, err := cert.Create()
+		mappings, policy, err := validateSyncOptions()
 		if err != nil {
 			return err
 		}
 
-		privateKeyFile, err := utils.CreateTempFile(privateKey)
+		ports, err := portMappings()
+		if err != nil {
+			return err
+		}
+
+		env, envFrom, err := environment()
+		if err != nil {
+			return err
+		}
+
+		patches, err := podPatches()
+		if err != nil {
+			return err
+		}
+
+		tolerations, affinity, err := scheduling()
+		if err != nil {
+			return err
+		}
+
+		requests, err := kubectl.ParseResources(opt.Requests)
+		if err != nil {
+			return errors.Wrap(err, "Invalid --requests")
+		}
+		limits, err := kubectl.ParseResources(opt.Limits)
+		if err != nil {
+			return errors.Wrap(err, "Invalid --limits")
+		}
+
+		var swapDeployment string
+		if opt.Swap {
+			kind, deployment, err := kubectl.ParseWorkloadReference(opt.From)
+			if err != nil || kind != "deployment" {
+				return errors.New("--swap requires --from deployment/NAME")
+			}
+			swapDeployment = deployment
+		}
+
+		securityProfile, err := kubectl.ParseSecurityProfile(opt.SecurityProfile)
+		if err != nil {
+			return err
+		}
+		transport, err := kubectl.ParseTransport(opt.Transport)
+		if err != nil {
+			return err
+		}
+		pullPolicy, err := kubectl.ParsePullPolicy(opt.ImagePullPolicy)
 		if err != nil {
 			return err
 		}
-		defer os.Remove(privateKeyFile)
+
+		volumeSize, err := resource.ParseQuantity(opt.VolumeSize)
+		if err != nil {
+			return errors.Wrap(err, "Invalid --volume-size")
+		}
+		for _, cache := range opt.Caches {
+			if !path.IsAbs(cache) {
+				return fmt.Errorf("Invalid --cache %q, must be absolute path in the container", cache)
+			}
+		}
 
 		if !opt.Stdin {
 			stdin = nil
 		}
 
-		ns, _, err := configFlags.ToRawKubeConfigLoader().Namespace()
+		ns, restConfig, c, err := newClient()
 		if err != nil {
 			return err
 		}
 
-		config, err := configFlags.ToRESTConfig()
+		keys, err := sshKeys(restConfig)
 		if err != nil {
 			return err
 		}
-		kubectl.SetKubernetesDefaults(config)
 
-		c := kubectl.NewClient(config)
+		labels, annotations := sessionMetadata(mappings)
+		var base *apiv1.PodSpec
+		if opt.From != "" {
+			template, err := c.GetPodTemplate(ns, opt.From)
+			if err != nil {
+				return errors.Wrapf(err, "Cannot get the Pod template from %s", opt.From)
+			}
+			base = &template.Spec
+
+			if opt.Swap {
+				// Copy the labels so the Service traffic goes to the warp Pod. The ReplicaSet doesn't adopt the
+				// Pod because its selector has the pod-template-hash label what the template doesn't have.
+				for key, value := range template.Labels {
+					if _, ok := labels[key]; !ok {
+						labels[key] = value
+					}
+				}
+			}
+		}
 
 		fmt.Fprintln(stderr, "Create the Pod")
-		_, err = c.CreatePod(ns, name, opt.Image, cmd, workDir, opt.TTY, opt.Stdin, publicKey, opt.ServiceAccountName, opt.NodeSelector)
+		created, err := c.CreatePod(ns, name, kubectl.PodOptions{
+			Image:              opt.Image,
+			Command:            command,
+			WorkDir:            opt.WorkDir,
+			SyncDirs:           remoteDirs(mappings),
+			TTY:                opt.TTY,
+			Stdin:              opt.Stdin,
+			ServiceAccountName: opt.ServiceAccountName,
+			NodeSelector:       opt.NodeSelector,
+			Tolerations:        tolerations,
+			Affinity:           affinity,
+			NodeName:           opt.NodeName,
+			PriorityClassName:  opt.PriorityClass,
+			Ports:              ports,
+			Env:                env,
+			EnvFrom:            envFrom,
+			Requests:           requests,
+			Limits:             limits,
+			Persistent:         opt.Persistent,
+			Caches:             opt.Caches,
+			StorageClass:       opt.StorageClass,
+			VolumeSize:         volumeSize,
+			Base:               base,
+			BaseContainer:      opt.Container,
+			Patches:            patches,
+			ActiveDeadline:     opt.ActiveDeadline,
+			SecurityProfile:    securityProfile,
+			Transport:          transport,
+			Syncer:             opt.Syncer,
+			SyncImage:          opt.SyncImage,
+			ImagePullPolicy:    pullPolicy,
+			ImagePullSecrets:   opt.ImagePullSecrets,
+			Labels:             labels,
+			Annotations:        annotations,
+		}, keys)
 		if err != nil {
 			return err
 		}
-		defer c.DeletePod(ns, name)
+		defer func() {
+			// Timed out Pod is never left running, even with --detach
+			_, timedOut := err.(*kubectl.TimeoutError)
+			closeSession(c, ns, name, opt.Detach && !timedOut, stderr)
+		}()
+
+		expired, stopTimeout := sessionTimeout(c, ns, name, opt.Timeout, stderr)
+		defer func() {
+			stopTimeout()
+			if expired() {
+				err = &kubectl.TimeoutError{Phase: "the command to complete", Timeout: opt.Timeout}
+			}
+		}()
+
+		if opt.Swap {
+			fmt.Fprintf(stderr, "Swap the Deployment %s to the Pod\n", swapDeployment)
+			if err := c.SwapDeployment(ns, swapDeployment, name); err != nil {
+				return err
+			}
+			// Mark the Pod only after the swap, so closing the session restores only its own swap
+			if err := c.AnnotatePod(ns, name, map[string]string{kubectl.SwappedDeploymentAnnotation: swapDeployment}); err != nil {
+				return err
+			}
+		}
+
+		stopHeartbeat := heartbeat(c, ns, name, stderr)
+		defer stopHeartbeat()
 
 		_, err = c.WaitForPod(ns, name, kubectl.PodInitReady)
 		if err != nil && err != kubectl.ErrPodCompleted {
@@ -119,24 +297,14 @@ along with the synchronized files.`,
 		// otherwise sometimes we get error "Connection refused" from the port 22
 		time.Sleep(100 * time.Millisecond)
 
-		// Until this bug is fixed, we cannot use 0 to make the PortForwarder to pick random port
-		// https://github.com/kubernetes/kubernetes/pull/71575
-		randomPort := utils.MustResolveRandomPort()
-		readyChannel := make(chan struct{}, 1)
-
-		fmt.Fprintln(stderr, "Open connection to the Pod")
-		f, err := kubectl.PreparePortForward(config, ns, name, []string{fmt.Sprintf("%d:%d", randomPort, 22)}, stopChannel, readyChannel, devNull, stderr)
+		s, cleanup, err := connect(c, restConfig, ns, name, created, keys, ports, stopChannel, stderr)
 		if err != nil {
 			return err
 		}
-		go f.ForwardPorts()
-
-		// Wait until port forwarding is ready
-		<-readyChannel
+		defer cleanup()
 
 		fmt.Fprintln(stderr, "Sync initial files to the Pod")
-		s := sync.NewRsync(randomPort, strings.Split(opt.RsyncArgs, " "), privateKeyFile, devNull, devNull)
-		if err := s.Sync(fmt.Sprintf("root@localhost:%s", workDir), opt.Includes, opt.Excludes); err != nil {
+		if err := initialSync(s, mappings); err != nil {
 			return err
 		}
 
@@ -144,36 +312,18 @@ along with the synchronized files.`,
 		if err != nil {
 			if err == kubectl.ErrPodCompleted {
 				fmt.Fprintf(stderr, "Pod %s execution container were already completed. Print logs out\n", name)
-				return logOutput(c, ns, name, containerName, stdout)
+				return printCompleted(c, ns, name, containerName, stdout)
 			}
 			return err
 		}
 		if pod.Status.Phase == apiv1.PodSucceeded || pod.Status.Phase == apiv1.PodFailed {
 			fmt.Fprintf(stderr, "Pod %s were already completed. Print logs to stdout\n", name)
-			return logOutput(c, ns, name, containerName, stdout)
+			return printCompleted(c, ns, name, containerName, stdout)
 		}
 
-		go func() {
-			if _, err := c.WaitForPod(ns, name, kubectl.ContainerRunning("sync")); err != nil {
-				fmt.Fprintf(stderr, "Error while waiting sync container to be started: %s\n", err)
-				return
-			}
-
-			fmt.Fprintln(stderr, "Start background file sync")
-			for {
-				select {
-				case <-time.After(1 * time.Second):
-					if err := s.Sync(fmt.Sprintf("root@localhost:%s", workDir), opt.Includes, opt.Excludes); err != nil {
-						fmt.Fprintf(stderr, "sync Failed: %s\n", err)
-					}
-				case <-stopChannel:
-					fmt.Fprintf(stderr, "sync: Stop %s syncing\n", name)
-					return
-				}
-			}
-		}()
+		go backgroundSync(c, ns, name, s, mappings, policy, stopChannel, stderr)
 
-		return c.Attach(ns, name, containerName, stdin, stdout, stderr, opt.TTY)
+		return attach(c, ns, name, containerName, s, mappings, policy, stdin, stdout, stderr, opt.TTY)
 	},
 	// We handle errors at root.go
 	SilenceUsage:  true,
@@ -181,27 +331,235 @@ along with the synchronized files.`,
 }
 
 func init() {
-	configFlags.AddFlags(rootCmd.Flags())
+	configFlags.AddFlags(rootCmd.PersistentFlags())
 
 	rootCmd.Flags().StringVar(&opt.Image, "image", opt.Image, "The image for the container to run.")
-	rootCmd.MarkFlagRequired("image")
-	rootCmd.Flags().StringVar(&opt.RsyncArgs, "rsync-args", "--recursive --times --links --devices --specials", "Space separated arguments for the rsync command")
 	rootCmd.Flags().BoolVarP(&opt.Stdin, "stdin", "i", opt.Stdin, "Pass stdin to the container")
 	rootCmd.Flags().BoolVarP(&opt.TTY, "tty", "t", opt.TTY, "Stdin is a TTY")
-	rootCmd.Flags().StringSliceVar(&opt.Includes, "include", []string{}, "Include only specific paths from current directory for syncing")
-	rootCmd.Flags().StringSliceVar(&opt.Excludes, "exclude", []string{}, "Exclude only specific paths from current directory for syncing")
 	rootCmd.Flags().StringVar(&opt.ServiceAccountName, "service-account-name", opt.ServiceAccountName, "The service account name that you want the pod to use")
 	rootCmd.Flags().StringToStringVar(&opt.NodeSelector, "node-selector", map[string]string{}, "The kay-value pairs used for the nodeSelector")
+	rootCmd.Flags().StringArrayVar(&opt.Tolerations, "toleration", []string{}, "Tolerate the node taint, in format KEY[=VALUE][:EFFECT]")
+	rootCmd.Flags().StringArrayVar(&opt.NodeAffinity, "node-affinity", []string{}, "Require node labels matching the selector expression, e.g. 'kubernetes.io/arch in (arm64,arm)'. The Pod is scheduled to a node matching any of the expressions")
+	rootCmd.Flags().StringVar(&opt.NodeName, "node-name", opt.NodeName, "Run the Pod in the given node")
+	rootCmd.Flags().StringVar(&opt.PriorityClass, "priority-class", opt.PriorityClass, "The priority class name for the Pod")
+	rootCmd.Flags().StringArrayVar(&opt.Env, "env", []string{}, "Environment variable for the command in format KEY=VALUE, or KEY to use the local value")
+	rootCmd.Flags().StringSliceVar(&opt.EnvFrom, "env-from", []string{}, "Set environment variables from configmap/NAME or secret/NAME")
+	rootCmd.Flags().StringSliceVar(&opt.EnvFiles, "env-file", []string{}, "Read environment variables from the file, the --env flags override the values")
+	rootCmd.Flags().StringToStringVar(&opt.Requests, "requests", map[string]string{}, "The resource requests for the command container, e.g. cpu=2,memory=4Gi,ephemeral-storage=10Gi")
+	rootCmd.Flags().StringToStringVar(&opt.Limits, "limits", map[string]string{}, "The resource limits for the command container, e.g. cpu=4,memory=8Gi,ephemeral-storage=20Gi")
+	rootCmd.Flags().StringVar(&opt.From, "from", opt.From, "Base the Pod on the existing deployment/NAME, statefulset/NAME, daemonset/NAME, job/NAME or pod/NAME")
+	rootCmd.Flags().StringVarP(&opt.Container, "container", "c", opt.Container, "The container in the --from Pod what runs the command, defaults to the first container")
+	rootCmd.Flags().BoolVar(&opt.Swap, "swap", opt.Swap, "Scale the --from deployment to zero and route its Service traffic to the warp Pod until exit")
+	rootCmd.Flags().BoolVar(&opt.Persistent, "persistent", opt.Persistent, "Keep the working directory in a PersistentVolumeClaim named NAME-workdir, so it's reused in the next session with the same NAME")
+	rootCmd.Flags().StringSliceVar(&opt.Caches, "cache", []string{}, "Keep the container directory in a PersistentVolumeClaim between the sessions, e.g. /root/.npm")
+	rootCmd.Flags().StringVar(&opt.StorageClass, "storage-class", opt.StorageClass, "The storage class for the --persistent and --cache volumes, defaults to the cluster default")
+	rootCmd.Flags().StringVar(&opt.VolumeSize, "volume-size", "10Gi", "The size of the --persistent and --cache volumes")
+	rootCmd.Flags().StringVar(&opt.Overrides, "overrides", opt.Overrides, "Inline JSON strategic merge patch for the generated Pod, e.g. '{\"spec\":{\"hostNetwork\":true}}'")
+	rootCmd.Flags().StringVar(&opt.PodTemplate, "pod-template", opt.PodTemplate, "Pod YAML or JSON file what is merged as strategic merge patch over the generated Pod, before the --overrides")
+	rootCmd.Flags().DurationVar(&opt.SyncTimeout, "sync-timeout", 5*time.Minute, "The maximum time to wait the initial sync to complete, 0 means no limit")
+	rootCmd.Flags().DurationVar(&opt.Timeout, "timeout", 0, "The maximum time for the whole session, from creating the Pod until the command completes. The Pod is deleted when it expires, 0 means no limit")
+	rootCmd.Flags().DurationVar(&opt.ActiveDeadline, "active-deadline", 24*time.Hour, "The maximum time the Pod can run before Kubernetes terminates it, 0 means no limit")
+	rootCmd.Flags().StringVar(&opt.SyncImage, "sync-image", kubectl.DefaultSyncImage, "The image for the sync containers, must have sshd and rsync, sshd and tar with --syncer tar, or only tar with --transport exec. Can be set with "+envFlags["sync-image"]+" environment variable")
+	rootCmd.Flags().StringVar(&opt.ImagePullPolicy, "image-pull-policy", opt.ImagePullPolicy, "The image pull policy for the warp containers, one of Always, IfNotPresent or Never. Can be set with "+envFlags["image-pull-policy"]+" environment variable")
+	rootCmd.Flags().StringSliceVar(&opt.ImagePullSecrets, "image-pull-secret", []string{}, "The Secret for pulling the images from private registry. Can be set with "+envFlags["image-pull-secret"]+" environment variable")
+	rootCmd.Flags().StringVar(&opt.Transport, "transport", string(kubectl.SSHTransport), "How the files are synced, ssh (port forwarding to sshd in the Pod) or exec (tar through kubectl exec, always uses the tar syncer)")
+	rootCmd.Flags().StringVar(&opt.SecurityProfile, "security-profile", string(kubectl.DefaultSecurityProfile), "The Pod security settings, default or restricted. The restricted runs all containers as non-root to pass the restricted Pod Security Standard")
+	rootCmd.Flags().StringVar(&opt.KeyType, "key-type", string(cert.Ed25519), "The type of the session SSH key, one of ed25519, ecdsa or rsa. The default changed from rsa to ed25519, use rsa if the sync image sshd doesn't support ed25519")
+	rootCmd.Flags().BoolVar(&opt.KeyCache, "key-cache", opt.KeyCache, "Reuse the session SSH key from the user config directory instead of generating new key for every session")
+	rootCmd.Flags().DurationVar(&opt.KeyMaxAge, "key-max-age", 7*24*time.Hour, "How long the --key-cache key is used before it gets replaced with new key, 0 means never")
+	addSyncFlags(rootCmd.Flags())
+}
+
+// sshKeys returns the session key pair, from the cache if --key-cache is set, and new host key pair
+func sshKeys(restConfig *rest.Config) (kubectl.SSHKeys, error) {
+	privateKey, publicKey, err := clientKeys(restConfig)
+	if err != nil {
+		return kubectl.SSHKeys{}, err
+	}
+
+	hostPrivateKey, hostPublicKey, err := cert.CreateHostKey()
+	if err != nil {
+		return kubectl.SSHKeys{}, err
+	}
+
+	return kubectl.SSHKeys{
+		PublicKey:      publicKey,
+		PrivateKey:     privateKey,
+		HostPublicKey:  hostPublicKey,
+		HostPrivateKey: hostPrivateKey,
+	}, nil
+}
+
+// clientKeys returns the client key pair from the key cache, or new key pair if the cache is not used
+func clientKeys(restConfig *rest.Config) ([]byte, []byte, error) {
+	keyType, err := cert.ParseKeyType(opt.KeyType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opt.KeyCache {
+		dir, err := cert.DefaultCacheDir()
+		if err != nil {
+			return nil, nil, err
+		}
+		privateKey, publicKey, err := cert.NewCache(dir, opt.KeyMaxAge).Get(restConfig.Host, keyType)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Cannot use the cached key")
+		}
+		return privateKey, publicKey, nil
+	}
+	return cert.Create(keyType)
+}
+
+// podPatches returns the strategic merge patches from the --pod-template and --overrides flags
+func podPatches() ([][]byte, error) {
+	patches := [][]byte{}
+	if opt.PodTemplate != "" {
+		content, err := ioutil.ReadFile(opt.PodTemplate)
+		if err != nil {
+			return nil, err
+		}
+		patch, err := yaml.YAMLToJSON(content)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid --pod-template %s", opt.PodTemplate)
+		}
+		patches = append(patches, patch)
+	}
+
+	if opt.Overrides != "" {
+		if !json.Valid([]byte(opt.Overrides)) {
+			return nil, errors.New("Invalid --overrides, must be valid JSON")
+		}
+		patches = append(patches, []byte(opt.Overrides))
+	}
+	return patches, nil
+}
+
+// scheduling returns the tolerations and the node affinity from the --toleration and --node-affinity flags
+func scheduling() ([]apiv1.Toleration, *apiv1.Affinity, error) {
+	tolerations := make([]apiv1.Toleration, len(opt.Tolerations))
+	for i, value := range opt.Tolerations {
+		toleration, err := kubectl.ParseToleration(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		tolerations[i] = toleration
+	}
+
+	affinity, err := kubectl.ParseNodeAffinity(opt.NodeAffinity)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tolerations, affinity, nil
+}
+
+// environment returns the environment variables for the command from the --env-file, --env and
+// --env-from flags
+func environment() ([]apiv1.EnvVar, []apiv1.EnvFromSource, error) {
+	values := []string{}
+	for _, path := range opt.EnvFiles {
+		fileValues, err := config.ReadEnvFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		values = append(values, fileValues...)
+	}
+
+	env, err := kubectl.ParseEnv(append(values, opt.Env...), os.LookupEnv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	envFrom := make([]apiv1.EnvFromSource, len(opt.EnvFrom))
+	for i, value := range opt.EnvFrom {
+		if envFrom[i], err = kubectl.ParseEnvFrom(value); err != nil {
+			return nil, nil, err
+		}
+	}
+	return env, envFrom, nil
 }
 
-// Execute run the root command
+// loadConfig uses the project configuration file and the environment variable values as defaults
+// for the flags what are not set in the command line
+func loadConfig(cmd *cobra.Command, profile string) error {
+	if err := loadConfigFile(cmd, profile); err != nil {
+		return err
+	}
+	// The environment variables override the configuration file, but not the command line flags
+	return config.ApplyEnv(cmd.Flags(), envFlags, os.LookupEnv)
+}
+
+// envFlags maps the flags what can be set with the environment variables to the variable names,
+// e.g. to use internal registry in all projects in the air-gapped environment
+var envFlags = map[string]string{
+	"sync-image":        "WARP_SYNC_IMAGE",
+	"image-pull-policy": "WARP_IMAGE_PULL_POLICY",
+	"image-pull-secret": "WARP_IMAGE_PULL_SECRET",
+}
+
+// pathOptions are the options what have local paths, the relative paths in the configuration file are
+// relative to the file directory
+var pathOptions = map[string]config.PathOption{
+	"env-file":     config.Path,
+	"pod-template": config.Path,
+	"sync":         syncLocalPath,
+}
+
+// loadConfigFile finds the project configuration file from the current or parent directories and
+// applies the values to the flags
+func loadConfigFile(cmd *cobra.Command, profile string) error {
+	path, err := config.Find(".")
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		if profile != "" {
+			return fmt.Errorf("Cannot use profile %s, %s file not found", profile, config.FileName)
+		}
+		return nil
+	}
+
+	c, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+
+	values, err := c.Values(profile)
+	if err != nil {
+		return err
+	}
+
+	// The file is for the warp command, other commands use only the options what they support
+	for name := range values {
+		if cmd.Root().Flags().Lookup(name) == nil && cmd.Root().PersistentFlags().Lookup(name) == nil {
+			return fmt.Errorf("Invalid configuration file %s: Unknown option %q", path, name)
+		}
+	}
+
+	config.ResolvePaths(values, filepath.Dir(path), pathOptions)
+
+	if err := config.Apply(cmd.Flags(), values); err != nil {
+		return errors.Wrapf(err, "Invalid configuration file %s", path)
+	}
+	return nil
+}
+
+// Execute run the root command and exits with the command exit code, or with exitCodeWarpFailure
+// if warp itself fails
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		if e, ok := err.(*exitError); ok {
+			os.Exit(e.code)
+		}
+		if _, ok := err.(*kubectl.TimeoutError); ok {
+			fmt.Println(err)
+			os.Exit(exitCodeTimeout)
+		}
 		if err.Error() == "interrupted" {
 			fmt.Println("Cancelling...")
-		} else {
-			fmt.Println(err)
+			os.Exit(exitCodeInterrupted)
 		}
-		os.Exit(1)
+		fmt.Println(err)
+		os.Exit(exitCodeWarpFailure)
 	}
 }
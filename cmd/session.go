@@ -0,0 +1,611 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"os/user"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ernoaapa/kubectl-warp/pkg/config"
+	"github.com/ernoaapa/kubectl-warp/pkg/kubectl"
+	"github.com/ernoaapa/kubectl-warp/pkg/sync"
+	"github.com/ernoaapa/kubectl-warp/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// addSyncFlags adds the flags what are needed to connect to the warp Pod and to keep the files in sync
+func addSyncFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&opt.Profile, "profile", opt.Profile, "The profile to use from the "+config.FileName+" file")
+	flags.StringVar(&opt.RsyncArgs, "rsync-args", "--recursive --times --links --devices --specials", "Space separated arguments for the rsync command")
+	flags.StringSliceVar(&opt.Includes, "include", []string{}, "Include only specific paths from current directory for syncing")
+	flags.StringSliceVar(&opt.Excludes, "exclude", []string{}, "Exclude only specific paths from current directory for syncing")
+	flags.StringVar(&opt.Syncer, "syncer", "rsync", "The sync implementation, rsync (requires local rsync and ssh binaries) or tar (built-in)")
+	flags.BoolVar(&opt.GitIgnore, "gitignore", true, "Exclude the .git directory and files listed in .gitignore files from syncing")
+	flags.StringVar(&opt.WorkDir, "workdir", "/work-dir", "The working directory for the command in the container, the current directory is synced there by default")
+	flags.StringArrayVar(&opt.SyncPaths, "sync", []string{}, "Sync the local directory to the container directory, in format LOCAL:REMOTE. Replaces the default sync of the current directory to the --workdir")
+	flags.StringSliceVar(&opt.Pull, "pull", []string{}, "Paths (relative to the first synced directory) to sync back from the Pod")
+	flags.DurationVar(&opt.PullInterval, "pull-interval", 2*time.Second, "How often to sync the --pull paths back from the Pod")
+	flags.StringVar(&opt.Conflict, "conflict", string(sync.NewestWins), "Which file to keep when pulled file exist locally, one of local, remote or newest")
+	flags.BoolVar(&opt.Poll, "poll", opt.Poll, "Poll for changes every second instead of watching the filesystem events")
+	flags.StringSliceVarP(&opt.Ports, "port", "p", []string{}, "Forward the local port to the exec container port, in format LOCAL:REMOTE or PORT")
+	flags.DurationVar(&opt.PodRunningTimeout, "pod-running-timeout", 5*time.Minute, "The maximum time to wait the Pod to be scheduled and the containers to start, 0 means no limit")
+	flags.BoolVar(&opt.Detach, "detach", opt.Detach, "Leave the Pod running on exit so you can reattach to it with 'warp attach NAME'")
+}
+
+// syncMapping is a local directory what is synced to the remote directory in the Pod
+type syncMapping struct {
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+}
+
+// validateSyncOptions validates the sync flags and returns the directories to sync and the conflict
+// policy for pulling the files
+func validateSyncOptions() ([]syncMapping, sync.ConflictPolicy, error) {
+	if opt.Syncer != "rsync" && opt.Syncer != "tar" {
+		return nil, "", fmt.Errorf("Invalid --syncer value %q, must be rsync or tar", opt.Syncer)
+	}
+
+	if !path.IsAbs(opt.WorkDir) {
+		return nil, "", fmt.Errorf("Invalid --workdir %q, must be absolute path", opt.WorkDir)
+	}
+
+	mappings, err := syncMappings()
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, p := range opt.Pull {
+		if clean := path.Clean(p); path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, "", fmt.Errorf("Invalid --pull path %q, must be relative to the synced directory", p)
+		}
+	}
+
+	policy, err := sync.ParseConflictPolicy(opt.Conflict)
+	return mappings, policy, err
+}
+
+// syncLocalPath is the config.PathOption for the --sync values, only the local directory is a local path
+func syncLocalPath(value string, resolve func(path string) string) string {
+	i := strings.LastIndex(value, ":")
+	if i <= 0 {
+		return value
+	}
+	return resolve(value[:i]) + value[i:]
+}
+
+// syncMappings returns the directories from the --sync flags, or the current directory synced to
+// the --workdir if there's none
+func syncMappings() ([]syncMapping, error) {
+	if len(opt.SyncPaths) == 0 {
+		return []syncMapping{{Local: ".", Remote: opt.WorkDir}}, nil
+	}
+
+	mappings := []syncMapping{}
+	remotes := map[string]bool{}
+	for _, value := range opt.SyncPaths {
+		// The local path might have colon, e.g. in Windows C:\project
+		i := strings.LastIndex(value, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("Invalid --sync %q, must be in format LOCAL:REMOTE", value)
+		}
+		m := syncMapping{Local: value[:i], Remote: path.Clean(value[i+1:])}
+
+		if !path.IsAbs(m.Remote) {
+			return nil, fmt.Errorf("Invalid --sync %q, the remote directory must be absolute path", value)
+		}
+		if remotes[m.Remote] {
+			return nil, fmt.Errorf("Invalid --sync %q, the remote directory is already synced", value)
+		}
+		remotes[m.Remote] = true
+
+		if info, err := os.Stat(m.Local); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("Invalid --sync %q, the local directory doesn't exist", value)
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
+
+// remoteDirs returns the remote directories of the mappings
+func remoteDirs(mappings []syncMapping) []string {
+	dirs := make([]string, len(mappings))
+	for i, m := range mappings {
+		dirs[i] = m.Remote
+	}
+	return dirs
+}
+
+// portMappings parses the --port flags
+func portMappings() ([]kubectl.PortMapping, error) {
+	ports := make([]kubectl.PortMapping, len(opt.Ports))
+	for i, value := range opt.Ports {
+		m, err := kubectl.ParsePortMapping(value)
+		if err != nil {
+			return nil, err
+		}
+		ports[i] = m
+	}
+	return ports, nil
+}
+
+// newClient returns the namespace, configuration and client from the kubeconfig and flags
+func newClient() (string, *rest.Config, *kubectl.Client, error) {
+	ns, _, err := configFlags.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	restConfig, err := configFlags.ToRESTConfig()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	kubectl.SetKubernetesDefaults(restConfig)
+
+	client := kubectl.NewClient(restConfig)
+	client.SetTimeout(opt.PodRunningTimeout)
+
+	return ns, restConfig, client, nil
+}
+
+// sessionMetadata returns the labels and annotations what tell who started the session, where and when,
+// and what directories it syncs
+func sessionMetadata(mappings []syncMapping) (map[string]string, map[string]string) {
+	owner := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		owner = u.Username
+	}
+	hostname, _ := os.Hostname()
+	sourceDir, _ := os.Getwd()
+	syncDirs, _ := json.Marshal(absMappings(mappings))
+
+	labels := map[string]string{
+		kubectl.OwnerLabel: kubectl.SanitizeLabelValue(owner),
+	}
+	annotations := map[string]string{
+		kubectl.OwnerAnnotation:     owner,
+		kubectl.HostnameAnnotation:  hostname,
+		kubectl.SourceDirAnnotation: sourceDir,
+		kubectl.StartTimeAnnotation: time.Now().UTC().Format(time.RFC3339),
+		kubectl.SyncDirsAnnotation:  string(syncDirs),
+	}
+	return labels, annotations
+}
+
+// absMappings returns the mappings with absolute local directories, so they work from any directory
+func absMappings(mappings []syncMapping) []syncMapping {
+	result := make([]syncMapping, len(mappings))
+	for i, m := range mappings {
+		result[i] = m
+		if local, err := filepath.Abs(m.Local); err == nil {
+			result[i].Local = local
+		}
+	}
+	return result
+}
+
+// podMappings returns the directories what the Pod were created with. The Pod volumes cannot change, so
+// the --sync and --workdir flags must match them if given. Pods without the annotation use the flags.
+func podMappings(flags *pflag.FlagSet, pod *apiv1.Pod, mappings []syncMapping) ([]syncMapping, error) {
+	value, ok := pod.Annotations[kubectl.SyncDirsAnnotation]
+	if !ok {
+		return mappings, nil
+	}
+
+	created := []syncMapping{}
+	if err := json.Unmarshal([]byte(value), &created); err != nil {
+		return nil, errors.Wrapf(err, "Invalid %s annotation in Pod %s", kubectl.SyncDirsAnnotation, pod.Name)
+	}
+
+	if (flags.Changed("sync") || flags.Changed("workdir")) && !reflect.DeepEqual(absMappings(mappings), created) {
+		dirs := make([]string, len(created))
+		for i, m := range created {
+			dirs[i] = m.Local + ":" + m.Remote
+		}
+		return nil, fmt.Errorf("The --sync and --workdir flags don't match the Pod %s directories %s, leave them out to use the Pod directories", pod.Name, strings.Join(dirs, ", "))
+	}
+	return created, nil
+}
+
+// interruptChannel returns channel what gets closed when user press ctrl+c and
+// function what must be called when done
+func interruptChannel() (chan struct{}, func()) {
+	stopChannel := make(chan struct{}, 1)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	go func() {
+		<-signals
+		close(stopChannel)
+	}()
+
+	return stopChannel, func() { signal.Stop(signals) }
+}
+
+// heartbeatInterval is how often the heartbeat annotation gets updated while connected to the Pod
+var heartbeatInterval = 1 * time.Minute
+
+// heartbeat updates the Pod heartbeat annotation periodically so 'warp gc' knows the Pod is still in use.
+// Clears the detached mark because someone is connected again. Returns function what stops the heartbeat.
+func heartbeat(c *kubectl.Client, namespace, name string, stderr io.Writer) func() {
+	beat := func(annotations map[string]string) {
+		annotations[kubectl.HeartbeatAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		if err := c.AnnotatePod(namespace, name, annotations); err != nil {
+			fmt.Fprintf(stderr, "heartbeat Failed: %s\n", err)
+		}
+	}
+	beat(map[string]string{kubectl.DetachedAnnotation: ""})
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				beat(map[string]string{})
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// closeSession deletes the Pod and restores the swapped Deployment, or marks the Pod detached and
+// leaves it running if detach is true
+func closeSession(c *kubectl.Client, namespace, name string, detach bool, stderr io.Writer) {
+	if !detach {
+		pod, err := c.GetPod(namespace, name)
+		c.DeletePod(namespace, name)
+
+		if err == nil && pod.Annotations[kubectl.SwappedDeploymentAnnotation] != "" {
+			deployment := pod.Annotations[kubectl.SwappedDeploymentAnnotation]
+			fmt.Fprintf(stderr, "Restore the Deployment %s\n", deployment)
+			if _, err := c.RestoreDeployment(namespace, deployment, name); err != nil {
+				fmt.Fprintf(stderr, "Failed to restore the Deployment %s, restore it with: kubectl warp restore %s: %s\n", deployment, deployment, err)
+			}
+		}
+		return
+	}
+
+	if err := c.AnnotatePod(namespace, name, map[string]string{kubectl.DetachedAnnotation: "true"}); err != nil {
+		fmt.Fprintf(stderr, "Failed to mark Pod %s detached: %s\n", name, err)
+	}
+	fmt.Fprintf(stderr, "Pod %s is left running, reattach with: kubectl warp attach %s\n", name, name)
+}
+
+// sessionTimeout deletes the Pod when the timeout expires, what stops the session in whatever phase it is.
+// Returns function what tells if the timeout has expired and function what stops the timer.
+// Zero timeout means no limit.
+func sessionTimeout(c *kubectl.Client, namespace, name string, timeout time.Duration, stderr io.Writer) (func() bool, func()) {
+	if timeout == 0 {
+		return func() bool { return false }, func() {}
+	}
+
+	var expired int32
+	timer := time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&expired, 1)
+		fmt.Fprintf(stderr, "Timed out after %s, delete the Pod %s\n", timeout, name)
+		if err := c.DeletePod(namespace, name); err != nil {
+			fmt.Fprintf(stderr, "Failed to delete Pod %s: %s\n", name, err)
+		}
+	})
+
+	return func() bool { return atomic.LoadInt32(&expired) == 1 }, func() { timer.Stop() }
+}
+
+// connect opens the connection for syncing the files with the transport what the Pod were created for, and
+// port forwarding to the application ports. Returns the syncer and cleanup function what must be called when done.
+func connect(c *kubectl.Client, restConfig *rest.Config, namespace, name string, pod *apiv1.Pod, keys kubectl.SSHKeys, ports []kubectl.PortMapping, stopChannel chan struct{}, stderr io.Writer) (initSyncer, func(), error) {
+	forwards := []string{}
+	for _, p := range ports {
+		forwards = append(forwards, p.String())
+	}
+
+	if kubectl.GetTransport(pod) == kubectl.ExecTransport {
+		if len(forwards) > 0 {
+			if err := forwardPorts(restConfig, namespace, name, forwards, ports, stopChannel, stderr); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		// The Pod is running only after the init containers complete, until that the files go to the
+		// sync-init container
+		container := "sync-init"
+		if pod.Status.Phase == apiv1.PodRunning {
+			container = "sync"
+		}
+		executor := c.NewExecutor(namespace, name, container)
+		return &execSyncer{Tar: sync.NewTar(executor, devNull, devNull), executor: executor}, func() {}, nil
+	}
+
+	// Until this bug is fixed, we cannot use 0 to make the PortForwarder to pick random port
+	// https://github.com/kubernetes/kubernetes/pull/71575
+	randomPort := utils.MustResolveRandomPort()
+	endpoint := kubectl.GetSSHEndpoint(pod)
+	forwards = append([]string{fmt.Sprintf("%d:%d", randomPort, endpoint.Port)}, forwards...)
+
+	if err := forwardPorts(restConfig, namespace, name, forwards, ports, stopChannel, stderr); err != nil {
+		return nil, nil, err
+	}
+
+	return newSyncer(randomPort, endpoint.User, keys)
+}
+
+// forwardPorts opens the port forwarding to the Pod and waits until it's ready
+func forwardPorts(restConfig *rest.Config, namespace, name string, forwards []string, ports []kubectl.PortMapping, stopChannel chan struct{}, stderr io.Writer) error {
+	readyChannel := make(chan struct{}, 1)
+
+	fmt.Fprintln(stderr, "Open connection to the Pod")
+	f, err := kubectl.PreparePortForward(restConfig, namespace, name, forwards, stopChannel, readyChannel, devNull, stderr)
+	if err != nil {
+		return err
+	}
+	errChannel := make(chan error, 1)
+	go func() { errChannel <- f.ForwardPorts() }()
+
+	// Wait until port forwarding is ready, fails e.g. if the local port is already in use
+	select {
+	case <-readyChannel:
+	case err := <-errChannel:
+		return errors.Wrap(err, "Port forwarding failed")
+	}
+
+	for _, p := range ports {
+		fmt.Fprintf(stderr, "Forwarding http://localhost:%d -> %d\n", p.Local, p.Remote)
+	}
+	return nil
+}
+
+// execSyncer is the tar syncer through the pods/exec API, it syncs to the sync-init container until the
+// initial sync is done
+type execSyncer struct {
+	*sync.Tar
+	executor *kubectl.Executor
+}
+
+// initSynced lets the sync-init container complete and switches to the sync container
+func (s *execSyncer) initSynced() error {
+	if err := s.executor.Execute("touch "+kubectl.SyncedMarker, nil, devNull, devNull); err != nil {
+		return err
+	}
+	s.executor.UseContainer("sync")
+	return nil
+}
+
+// initSyncer is the syncer what can tell the sync-init container that the initial sync is done
+type initSyncer interface {
+	sync.Syncer
+	initSynced() error
+}
+
+// initialSync syncs all the directories to the sync-init container and then lets it complete, so the
+// command starts with all the files in place
+func initialSync(s initSyncer, mappings []syncMapping) error {
+	if err := syncFilesWithTimeout(s, opt.SyncTimeout, mappings...); err != nil {
+		return err
+	}
+	return s.initSynced()
+}
+
+// backgroundSync waits until the sync container is running and then keeps the files in sync
+// until the stopChannel gets closed
+func backgroundSync(c *kubectl.Client, namespace, name string, s sync.Syncer, mappings []syncMapping, policy sync.ConflictPolicy, stopChannel chan struct{}, stderr io.Writer) {
+	if _, err := c.WaitForPod(namespace, name, kubectl.ContainerRunning("sync")); err != nil {
+		fmt.Fprintf(stderr, "Error while waiting sync container to be started: %s\n", err)
+		return
+	}
+
+	// Each mapping has its own watcher, changes tell which mapping must be synced
+	changes := make(chan syncMapping)
+	for _, m := range mappings {
+		watcher := newWatcher(m.Local, stderr)
+		defer watcher.Close()
+
+		go func(m syncMapping, watcher sync.Watcher) {
+			for {
+				select {
+				case <-watcher.Changes():
+					select {
+					case changes <- m:
+					case <-stopChannel:
+						return
+					}
+				case <-stopChannel:
+					return
+				}
+			}
+		}(m, watcher)
+	}
+
+	var pull <-chan time.Time
+	if len(opt.Pull) > 0 {
+		ticker := time.NewTicker(opt.PullInterval)
+		defer ticker.Stop()
+		pull = ticker.C
+	}
+
+	fmt.Fprintln(stderr, "Start background file sync")
+	// Sync once to catch the changes made after the initial sync but before the watching started
+	if err := syncFiles(s, mappings...); err != nil {
+		fmt.Fprintf(stderr, "sync Failed: %s\n", err)
+	}
+	for {
+		select {
+		case m := <-changes:
+			if err := syncFiles(s, m); err != nil {
+				fmt.Fprintf(stderr, "sync Failed: %s\n", err)
+			}
+		case <-pull:
+			if err := pullFiles(s, mappings, policy); err != nil {
+				fmt.Fprintf(stderr, "pull Failed: %s\n", err)
+			}
+		case <-stopChannel:
+			fmt.Fprintf(stderr, "sync: Stop %s syncing\n", name)
+			return
+		}
+	}
+}
+
+// attach attaches to the container, pulls the files once more when the container exits and
+// returns exitError if the command failed
+func attach(c *kubectl.Client, namespace, name, containerName string, s sync.Syncer, mappings []syncMapping, policy sync.ConflictPolicy, stdin io.Reader, stdout, stderr io.Writer, tty bool) error {
+	err := c.Attach(namespace, name, containerName, stdin, stdout, stderr, tty)
+
+	if len(opt.Pull) > 0 {
+		// Pull once more to get the files what the command generated just before exiting
+		fmt.Fprintln(stderr, "Pull files from the Pod")
+		if err := pullFiles(s, mappings, policy); err != nil {
+			fmt.Fprintf(stderr, "pull Failed: %s\n", err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return commandResult(c, namespace, name, containerName)
+}
+
+// commandResult waits until the container terminates and returns exitError if the command failed
+func commandResult(c *kubectl.Client, namespace, name, containerName string) error {
+	code, err := c.ExitCode(namespace, name, containerName)
+	if err != nil {
+		return errors.Wrap(err, "Cannot resolve the command exit code")
+	}
+	if code != 0 {
+		return &exitError{code: code}
+	}
+	return nil
+}
+
+// newSyncer returns the syncer selected with --syncer flag and cleanup function what must be called when done
+func newSyncer(sshPort uint16, user string, keys kubectl.SSHKeys) (initSyncer, func(), error) {
+	executor, err := sync.NewSSHExecutor(sshPort, user, keys.PrivateKey, keys.HostPublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opt.Syncer == "tar" {
+		return &sshSyncer{Syncer: sync.NewTar(executor, devNull, devNull), executor: executor}, func() {}, nil
+	}
+
+	agent, err := sync.NewAgent(keys.PrivateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	rsync, err := sync.NewRsync(sshPort, user, strings.Split(opt.RsyncArgs, " "), agent.Socket(), keys.HostPublicKey, devNull, devNull)
+	if err != nil {
+		agent.Close()
+		return nil, nil, err
+	}
+	return &sshSyncer{Syncer: rsync, executor: executor}, func() {
+		rsync.Close()
+		agent.Close()
+	}, nil
+}
+
+// sshSyncer is the syncer over the SSH connection, the executor runs the other commands in the Pod
+type sshSyncer struct {
+	sync.Syncer
+	executor *sync.SSHExecutor
+}
+
+// initSynced lets the sync-init container complete
+func (s *sshSyncer) initSynced() error {
+	return s.executor.Execute("touch "+kubectl.SyncedMarker, nil, devNull, devNull)
+}
+
+// waitAuthorized waits until the sshd in the Pod accepts the client key. The kubelet updates the Secret
+// volume with a delay, so the key what attach authorizes doesn't work right away.
+func (s *sshSyncer) waitAuthorized(timeout time.Duration) error {
+	start := time.Now()
+	for {
+		err := s.executor.Execute("true", nil, devNull, devNull)
+		if err == nil {
+			return nil
+		}
+		if timeout > 0 && time.Since(start) > timeout {
+			return errors.Wrap(err, "The Pod didn't accept the new session key")
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// syncFiles synchronises the local directory files to the remote directory of each mapping with the syncer
+func syncFiles(s sync.Syncer, mappings ...syncMapping) error {
+	for _, m := range mappings {
+		filter, err := syncFilter(m.Local)
+		if err != nil {
+			return err
+		}
+		if err := s.Sync(m.Local, m.Remote, filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncFilesWithTimeout synchronises the directories like syncFiles but returns TimeoutError if it doesn't
+// complete within the timeout, zero timeout means no limit
+func syncFilesWithTimeout(s sync.Syncer, timeout time.Duration, mappings ...syncMapping) error {
+	if timeout == 0 {
+		return syncFiles(s, mappings...)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- syncFiles(s, mappings...) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return &kubectl.TimeoutError{Phase: "the initial sync to complete", Timeout: timeout}
+	}
+}
+
+// pullFiles synchronises the --pull paths back from the remote directory of the first mapping
+func pullFiles(s sync.Syncer, mappings []syncMapping, policy sync.ConflictPolicy) error {
+	return s.Pull(mappings[0].Remote, mappings[0].Local, opt.Pull, policy)
+}
+
+// syncFilter loads the filter rules for the directory from the --include and --exclude flags and the
+// ignore files. The .warpignore rules take precedence over the .gitignore rules in the same directory.
+func syncFilter(root string) (*sync.Filter, error) {
+	excludes := append([]string{}, opt.Excludes...)
+	ignoreFiles := []string{".warpignore"}
+	if opt.GitIgnore {
+		excludes = append(excludes, ".git/")
+		ignoreFiles = []string{".gitignore", ".warpignore"}
+	}
+	return sync.LoadFilter(root, opt.Includes, excludes, ignoreFiles)
+}
+
+// newWatcher returns watcher for the directory file changes. Falls back to polling
+// if watching the filesystem events is not possible, e.g. the inotify watch limit is reached.
+func newWatcher(root string, stderr io.Writer) sync.Watcher {
+	if opt.Poll {
+		return sync.NewPoller(1 * time.Second)
+	}
+
+	filter, err := syncFilter(root)
+	if err != nil {
+		fmt.Fprintf(stderr, "Cannot load sync filter, fallback to polling: %s\n", err)
+		return sync.NewPoller(1 * time.Second)
+	}
+
+	watcher, err := sync.NewFSWatcher(root, 200*time.Millisecond, filter)
+	if err != nil {
+		fmt.Fprintf(stderr, "Cannot watch file changes, fallback to polling: %s\n", err)
+		return sync.NewPoller(1 * time.Second)
+	}
+	return watcher
+}
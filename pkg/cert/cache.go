@@ -0,0 +1,147 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Cache stores the generated key pairs on the disk, separately for each cluster, so the next
+// sessions can skip the key generation
+type Cache struct {
+	dir    string
+	maxAge time.Duration
+}
+
+// NewCache creates new cache what stores the keys in the directory and rotates them when they get
+// older than maxAge, zero maxAge means the keys never rotate
+func NewCache(dir string, maxAge time.Duration) *Cache {
+	return &Cache{
+		dir:    dir,
+		maxAge: maxAge,
+	}
+}
+
+// DefaultCacheDir returns the key cache directory under the user config directory
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "kubectl-warp", "keys"), nil
+}
+
+// Get returns the cached key pair for the cluster, or creates and stores new one if there is no
+// valid key pair yet or the key is older than the max age. Returns error if the cached files can be
+// accessed by the other users.
+func (c *Cache) Get(cluster string, keyType KeyType) ([]byte, []byte, error) {
+	dir := filepath.Join(c.dir, clusterDirName(cluster))
+	privateKeyFile := filepath.Join(dir, "id_"+string(keyType))
+	publicKeyFile := privateKeyFile + ".pub"
+
+	privateKey, publicKey, err := c.read(privateKeyFile, publicKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if privateKey != nil {
+		return privateKey, publicKey, nil
+	}
+
+	privateKey, publicKey, err = Create(keyType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, nil, err
+	}
+	// Write the public key first, so the private key file is never without the public key
+	if err := writeFile(publicKeyFile, publicKey); err != nil {
+		return nil, nil, err
+	}
+	if err := writeFile(privateKeyFile, privateKey); err != nil {
+		return nil, nil, err
+	}
+	return privateKey, publicKey, nil
+}
+
+// read returns the cached key pair, or nil if not found, too old or invalid
+func (c *Cache) read(privateKeyFile, publicKeyFile string) ([]byte, []byte, error) {
+	for _, path := range []string{filepath.Dir(privateKeyFile), privateKeyFile} {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			return nil, nil, fmt.Errorf("Permissions %#o for %s are too open, it must not be accessible by others. Fix the permissions or remove it", info.Mode().Perm(), path)
+		}
+		if path == privateKeyFile && c.maxAge > 0 && time.Since(info.ModTime()) > c.maxAge {
+			return nil, nil, nil
+		}
+	}
+
+	privateKey, err := ioutil.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	publicKey, err := ioutil.ReadFile(publicKeyFile)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !keyPairMatch(privateKey, publicKey) {
+		return nil, nil, nil
+	}
+	return privateKey, publicKey, nil
+}
+
+// keyPairMatch returns true if the public key belongs to the private key
+func keyPairMatch(privateKey, publicKey []byte) bool {
+	key, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		return false
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ssh.MarshalAuthorizedKey(signer.PublicKey()), publicKey)
+}
+
+// writeFile writes the file through temporary file, so the readers never see partially written file
+func writeFile(path string, content []byte) error {
+	tmpfile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpfile.Name())
+
+	// TempFile creates the file with 0600 permissions
+	if _, err := tmpfile.Write(content); err != nil {
+		tmpfile.Close()
+		return err
+	}
+	if err := tmpfile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpfile.Name(), path)
+}
+
+// clusterDirName returns file name safe directory name for the cluster
+func clusterDirName(cluster string) string {
+	sum := sha256.Sum256([]byte(cluster))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,62 @@
+package cert
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheReusesKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warp-keys")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cache := NewCache(dir, time.Hour)
+	privateKey, publicKey, err := cache.Get("https://cluster-a", Ed25519)
+	require.NoError(t, err)
+
+	cachedPrivateKey, cachedPublicKey, err := cache.Get("https://cluster-a", Ed25519)
+	require.NoError(t, err)
+	require.Equal(t, privateKey, cachedPrivateKey)
+	require.Equal(t, publicKey, cachedPublicKey)
+
+	otherPrivateKey, _, err := cache.Get("https://cluster-b", Ed25519)
+	require.NoError(t, err)
+	require.NotEqual(t, privateKey, otherPrivateKey)
+}
+
+func TestCacheRotatesOldKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warp-keys")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cache := NewCache(dir, time.Hour)
+	privateKey, _, err := cache.Get("https://cluster", ECDSA)
+	require.NoError(t, err)
+
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, clusterDirName("https://cluster"), "id_ecdsa"), old, old))
+
+	rotatedPrivateKey, _, err := cache.Get("https://cluster", ECDSA)
+	require.NoError(t, err)
+	require.NotEqual(t, privateKey, rotatedPrivateKey)
+}
+
+func TestCacheRejectsOpenPermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warp-keys")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cache := NewCache(dir, 0)
+	_, _, err = cache.Get("https://cluster", Ed25519)
+	require.NoError(t, err)
+
+	require.NoError(t, os.Chmod(filepath.Join(dir, clusterDirName("https://cluster"), "id_ed25519"), 0644))
+
+	_, _, err = cache.Get("https://cluster", Ed25519)
+	require.Error(t, err)
+}
@@ -1,18 +1,100 @@
 package cert
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
 
+	xed25519 "golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/ssh"
 )
 
 const bitSize = 4096
 
-// Create new SSH RSA public/private key pair
-func Create() ([]byte, []byte, error) {
+// KeyType is the type of the generated SSH key pair
+type KeyType string
+
+const (
+	// RSA is 4096 bit RSA key, slow to generate but supported everywhere
+	RSA KeyType = "rsa"
+	// ECDSA is ECDSA key with P-256 curve
+	ECDSA KeyType = "ecdsa"
+	// Ed25519 is the fastest to generate and requires OpenSSH 6.5 or newer in the Pod
+	Ed25519 KeyType = "ed25519"
+)
+
+// ParseKeyType validates the key type name
+func ParseKeyType(value string) (KeyType, error) {
+	switch t := KeyType(value); t {
+	case RSA, ECDSA, Ed25519:
+		return t, nil
+	}
+	return "", fmt.Errorf("Invalid key type %q, must be one of rsa, ecdsa or ed25519", value)
+}
+
+// Create new SSH public/private key pair, returns the private key in PEM format and the public key
+// in authorized_keys format
+func Create(keyType KeyType) ([]byte, []byte, error) {
+	switch keyType {
+	case ECDSA:
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		return encodePKCS8(privateKey, &privateKey.PublicKey)
+	case Ed25519:
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		return encodePKCS8(privateKey, publicKey)
+	}
+	return createRSA()
+}
+
+// ParsePrivateKey parses the private key what Create returns. Unlike ssh.ParseRawPrivateKey, supports
+// the Ed25519 keys in PKCS#8 format.
+func ParsePrivateKey(pemBytes []byte) (interface{}, error) {
+	key, err := ssh.ParseRawPrivateKey(pemBytes)
+	if err != nil {
+		return nil, err
+	}
+	// The ssh package knows only the x/crypto Ed25519 type
+	if k, ok := key.(ed25519.PrivateKey); ok {
+		return xed25519.PrivateKey(k), nil
+	}
+	return key, nil
+}
+
+func encodePKCS8(privateKey interface{}, publicKey interface{}) ([]byte, []byte, error) {
+	if k, ok := publicKey.(ed25519.PublicKey); ok {
+		// The ssh package knows only the x/crypto Ed25519 type
+		publicKey = xed25519.PublicKey(k)
+	}
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privatePEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: privDER,
+	})
+
+	return privatePEM, ssh.MarshalAuthorizedKey(sshPublicKey), nil
+}
+
+func createRSA() ([]byte, []byte, error) {
 	privateKey, err := rsa.GenerateKey(rand.Reader, bitSize)
 	if err != nil {
 		return nil, nil, err
@@ -30,6 +112,32 @@ func Create() ([]byte, []byte, error) {
 	return encodePrivateKeyToPEM(privateKey), ssh.MarshalAuthorizedKey(publicKey), nil
 }
 
+// CreateHostKey creates new ECDSA key pair for the Pod sshd host key, returns the private key in PEM format
+// and the public key in authorized_keys format
+func CreateHostKey() ([]byte, []byte, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	publicKey, err := ssh.NewPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privDER, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privatePEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "EC PRIVATE KEY",
+		Bytes: privDER,
+	})
+
+	return privatePEM, ssh.MarshalAuthorizedKey(publicKey), nil
+}
+
 // encodePrivateKeyToPEM encodes Private Key from RSA to PEM format
 func encodePrivateKeyToPEM(privateKey *rsa.PrivateKey) []byte {
 	// Get ASN.1 DER format
@@ -0,0 +1,27 @@
+package cert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreate(t *testing.T) {
+	for _, keyType := range []KeyType{RSA, ECDSA, Ed25519} {
+		privateKey, publicKey, err := Create(keyType)
+		require.NoError(t, err)
+
+		_, err = ParsePrivateKey(privateKey)
+		require.NoError(t, err, string(keyType))
+		require.True(t, keyPairMatch(privateKey, publicKey), string(keyType))
+	}
+}
+
+func TestParseKeyType(t *testing.T) {
+	keyType, err := ParseKeyType("ed25519")
+	require.NoError(t, err)
+	require.Equal(t, Ed25519, keyType)
+
+	_, err = ParseKeyType("dsa")
+	require.Error(t, err)
+}
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+// FileName is the name of the project configuration file
+const FileName = ".warp.yaml"
+
+// profilesKey is the key in the configuration file what holds the named profiles
+const profilesKey = "profiles"
+
+// Config is the project configuration what provides default values for the command line flags.
+// The keys are the flag names without the dashes, e.g.
+//
+//	image: node
+//	exclude: [node_modules/***]
+//	profiles:
+//	  arm64:
+//	    node-selector:
+//	      kubernetes.io/arch: arm64
+type Config struct {
+	// Path is the path to the configuration file
+	Path string
+
+	defaults map[string]interface{}
+	profiles map[string]map[string]interface{}
+}
+
+// Find searches the configuration file from the directory and its parent directories.
+// Returns empty string if the file is not found.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Load reads the configuration file
+func Load(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+	// Keep numbers as they are written, e.g. 1000000 instead of 1e+06
+	useNumber := func(d *json.Decoder) *json.Decoder {
+		d.UseNumber()
+		return d
+	}
+	if err := yaml.Unmarshal(content, &values, useNumber); err != nil {
+		return nil, fmt.Errorf("Invalid configuration file %s: %s", path, err)
+	}
+
+	c := &Config{
+		Path:     path,
+		defaults: values,
+		profiles: map[string]map[string]interface{}{},
+	}
+
+	if profiles, ok := values[profilesKey]; ok {
+		delete(values, profilesKey)
+
+		m, ok := profiles.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Invalid configuration file %s: %s must be a map of profiles", path, profilesKey)
+		}
+		for name, profile := range m {
+			if c.profiles[name], ok = profile.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("Invalid configuration file %s: profile %s must be a map of options", path, name)
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// Values returns the configuration values with the profile values applied over the defaults.
+// Empty profile returns only the defaults.
+func (c *Config) Values(profile string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for key, value := range c.defaults {
+		values[key] = value
+	}
+
+	if profile == "" {
+		return values, nil
+	}
+
+	p, ok := c.profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("Profile %s not found from %s", profile, c.Path)
+	}
+	for key, value := range p {
+		values[key] = value
+	}
+	return values, nil
+}
+
+// Apply sets the values to the flags what are not set in the command line, so the command line flags
+// always override the configuration values. Values for flags what are not in the flag set are ignored.
+func Apply(flags *pflag.FlagSet, values map[string]interface{}) error {
+	for _, name := range sortedKeys(values) {
+		flag := flags.Lookup(name)
+		if flag == nil || flag.Changed {
+			continue
+		}
+
+		if err := setValue(flag, values[name]); err != nil {
+			return fmt.Errorf("Invalid value for option %q: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// ApplyEnv sets the values from the environment variables to the flags what are not set in the command
+// line. The names map the flag names to the environment variable names. Lists are comma separated and
+// get added to the configuration file values.
+func ApplyEnv(flags *pflag.FlagSet, names map[string]string, lookup func(string) (string, bool)) error {
+	flagNames := make([]string, 0, len(names))
+	for name := range names {
+		flagNames = append(flagNames, name)
+	}
+	sort.Strings(flagNames)
+
+	for _, name := range flagNames {
+		flag := flags.Lookup(name)
+		if flag == nil || flag.Changed {
+			continue
+		}
+
+		value, ok := lookup(names[name])
+		if !ok || value == "" {
+			continue
+		}
+		if err := flag.Value.Set(value); err != nil {
+			return fmt.Errorf("Invalid value for environment variable %s: %s", names[name], err)
+		}
+	}
+	return nil
+}
+
+// PathOption returns the option value with the relative paths in it changed by the resolve function
+type PathOption func(value string, resolve func(path string) string) string
+
+// Path is the PathOption for the options what are plain paths
+func Path(value string, resolve func(path string) string) string {
+	return resolve(value)
+}
+
+// ResolvePaths changes the relative paths in the option values to be relative to the directory, so the
+// configuration file works the same from any subdirectory. The options map the option names to the
+// functions what find the paths from the values.
+func ResolvePaths(values map[string]interface{}, dir string, options map[string]PathOption) {
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+
+	for name, option := range options {
+		switch v := values[name].(type) {
+		case string:
+			values[name] = option(v, resolve)
+
+		case []interface{}:
+			resolved := make([]interface{}, len(v))
+			for i, item := range v {
+				resolved[i] = option(fmt.Sprint(item), resolve)
+			}
+			values[name] = resolved
+		}
+	}
+}
+
+// setValue sets the value to the flag. Lists set the value multiple times like repeating the flag,
+// and maps set each key-value pair as key=value
+func setValue(flag *pflag.Flag, value interface{}) error {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if err := flag.Value.Set(fmt.Sprint(item)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			if err := flag.Value.Set(fmt.Sprintf("%s=%v", key, v[key])); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case nil:
+		return nil
+	}
+
+	return flag.Value.Set(fmt.Sprint(value))
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+image: node
+exclude:
+  - node_modules/***
+  - dist/
+node-selector:
+  disktype: ssd
+replicas: 1000000
+profiles:
+  arm64:
+    image: arm64v8/node
+    node-selector:
+      kubernetes.io/arch: arm64
+`
+
+func TestFindFromParentDirectory(t *testing.T) {
+	root, err := ioutil.TempDir("", "warp-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	sub := filepath.Join(root, "services", "api")
+	require.NoError(t, os.MkdirAll(sub, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, FileName), []byte(testConfig), 0644))
+
+	path, err := Find(sub)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(root, FileName), path)
+}
+
+func TestApplyProfile(t *testing.T) {
+	file, err := ioutil.TempFile("", "warp-config")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(testConfig)
+	require.NoError(t, err)
+	file.Close()
+
+	c, err := Load(file.Name())
+	require.NoError(t, err)
+
+	values, err := c.Values("arm64")
+	require.NoError(t, err)
+
+	var (
+		image        string
+		excludes     []string
+		nodeSelector map[string]string
+		replicas     int
+	)
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringVar(&image, "image", "", "")
+	flags.StringSliceVar(&excludes, "exclude", []string{"default"}, "")
+	flags.StringToStringVar(&nodeSelector, "node-selector", map[string]string{}, "")
+	flags.IntVar(&replicas, "replicas", 0, "")
+	require.NoError(t, flags.Parse([]string{"--image", "from-cli"}))
+
+	require.NoError(t, Apply(flags, values))
+	require.Equal(t, "from-cli", image)
+	require.Equal(t, []string{"node_modules/***", "dist/"}, excludes)
+	require.Equal(t, map[string]string{"kubernetes.io/arch": "arm64"}, nodeSelector)
+	require.Equal(t, 1000000, replicas)
+
+	_, err = c.Values("missing")
+	require.Error(t, err)
+}
+
+func TestApplyEnv(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	syncImage := flags.String("sync-image", "sshd-rsync", "")
+	pullPolicy := flags.String("image-pull-policy", "", "")
+	secrets := flags.StringSlice("image-pull-secret", []string{}, "")
+	require.NoError(t, flags.Parse([]string{"--image-pull-policy", "Always"}))
+
+	env := map[string]string{
+		"WARP_SYNC_IMAGE":        "registry.local/sshd-rsync",
+		"WARP_IMAGE_PULL_POLICY": "Never",
+		"WARP_IMAGE_PULL_SECRET": "registry,mirror",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	err := ApplyEnv(flags, map[string]string{
+		"sync-image":        "WARP_SYNC_IMAGE",
+		"image-pull-policy": "WARP_IMAGE_PULL_POLICY",
+		"image-pull-secret": "WARP_IMAGE_PULL_SECRET",
+		"unknown":           "WARP_UNKNOWN",
+	}, lookup)
+	require.NoError(t, err)
+
+	require.Equal(t, "registry.local/sshd-rsync", *syncImage)
+	require.Equal(t, "Always", *pullPolicy)
+	require.Equal(t, []string{"registry", "mirror"}, *secrets)
+}
+
+func TestResolvePaths(t *testing.T) {
+	values := map[string]interface{}{
+		"env-file":     []interface{}{".env", "/etc/warp/env"},
+		"pod-template": "deploy/pod.yaml",
+		"image":        "node",
+	}
+
+	ResolvePaths(values, "/project", map[string]PathOption{
+		"env-file":     Path,
+		"pod-template": Path,
+		"missing":      Path,
+	})
+
+	require.Equal(t, map[string]interface{}{
+		"env-file":     []interface{}{filepath.Join("/project", ".env"), "/etc/warp/env"},
+		"pod-template": filepath.Join("/project", "deploy", "pod.yaml"),
+		"image":        "node",
+	}, values)
+}
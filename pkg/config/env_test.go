@@ -0,0 +1,34 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warp-env")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ".env")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+# comment
+FOO=bar
+export DEBUG=true
+QUOTED="hello world"
+SINGLE='a=b'
+EMPTY=
+`), 0644))
+
+	env, err := ReadEnvFile(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"FOO=bar", "DEBUG=true", "QUOTED=hello world", "SINGLE=a=b", "EMPTY="}, env)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("INVALID\n"), 0644))
+	_, err = ReadEnvFile(path)
+	require.Error(t, err)
+}
@@ -2,13 +2,18 @@ package kubectl
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"time"
 
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/typed/core/v1"
@@ -32,6 +37,11 @@ func NewClient(config *rest.Config) *Client {
 	}
 }
 
+// SetTimeout sets how long WaitForPod waits for the Pod to reach the condition, zero means no limit
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
 func (c *Client) getClient(namespace string) (v1.PodInterface, error) {
 	clientset, err := kubernetes.NewForConfig(c.config)
 	if err != nil {
@@ -60,21 +70,149 @@ func (c *Client) findPodByName(namespace, name string) (*apiv1.Pod, error) {
 	return &apiv1.Pod{}, ErrWithMessagef(ErrNotFound, "Pod with name %s not found", name)
 }
 
-func (c *Client) CreatePod(namespace, name, image string, cmd []string, workDir string, tty, stdin bool, publicKey []byte, scvAccName string, nodeSelectors map[string]string) (*apiv1.Pod, error) {
-	if err := c.createSSHSecret(namespace, name, publicKey); err != nil {
+// CreatePod creates the Secret for the SSH keys and the warp Pod
+// The Secret is owned by the Pod so it gets deleted along with the Pod.
+func (c *Client) CreatePod(namespace, name string, opts PodOptions, keys SSHKeys) (*apiv1.Pod, error) {
+	// Remove possible leftover from the previous session, but keep the Secret of the running Pod
+	if err := c.deleteLeftoverSecret(namespace, name); err != nil {
+		return nil, err
+	}
+
+	if err := c.ensureVolumes(namespace, name, opts); err != nil {
 		return nil, err
 	}
 
+	// Pod waits in ContainerCreating state until the Secret gets created
+	pod, err := c.createPod(namespace, name, opts)
+	if err != nil {
+		if errors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("Pod %s already exists in namespace %s, attach to it with: kubectl warp attach %s", name, namespace, name)
+		}
+		if isResourceRejection(err) {
+			return nil, fmt.Errorf("Pod rejected by the ResourceQuota or LimitRange in namespace %s, adjust the --requests and --limits: %s", namespace, err)
+		}
+		return nil, err
+	}
+
+	if err := c.createSSHSecret(namespace, name, opts, pod, keys); err != nil {
+		c.DeletePod(namespace, name)
+		return nil, err
+	}
+
+	return pod, nil
+}
+
+// createPod creates the Pod from the manifest with the overrides applied
+func (c *Client) createPod(namespace, name string, opts PodOptions) (*apiv1.Pod, error) {
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Base != nil {
+		if err := validateBase(name, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	manifest, err := patchPodManifest(createPodManifest(name, opts), append(securityPatches(opts), opts.Patches...))
+	if err != nil {
+		return nil, err
+	}
+
+	// Post the JSON as it is, so the overrides can use the fields what this client doesn't know
+	pod := &apiv1.Pod{}
+	err = clientset.CoreV1().RESTClient().Post().
+		Namespace(namespace).
+		Resource("pods").
+		SetHeader("Content-Type", "application/json").
+		Body(manifest).
+		Do().
+		Into(pod)
+	return pod, err
+}
+
+// GetPod returns the Pod with the name
+func (c *Client) GetPod(namespace, name string) (*apiv1.Pod, error) {
 	client, err := c.getClient(namespace)
 	if err != nil {
 		return nil, err
 	}
 
-	return client.Create(createPodManifest(name, image, cmd, workDir, tty, stdin, scvAccName, nodeSelectors))
+	pod, err := client.Get(name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, ErrWithMessagef(ErrNotFound, "Pod with name %s not found", name)
+	}
+	return pod, err
 }
 
-// WaitForPod watches the given pod until the exitCondition is true
+// getEvents returns the warning events of the Pod, oldest first. Errors are ignored because the events
+// are only additional information.
+func (c *Client) getEvents(namespace, name string) []string {
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return nil
+	}
+
+	selector := fields.Set{
+		"involvedObject.kind": "Pod",
+		"involvedObject.name": name,
+		"type":                apiv1.EventTypeWarning,
+	}.AsSelector().String()
+	list, err := clientset.CoreV1().Events(namespace).List(metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return nil
+	}
+
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].LastTimestamp.Before(&list.Items[j].LastTimestamp)
+	})
+
+	events := []string{}
+	for _, event := range list.Items {
+		events = append(events, fmt.Sprintf("%s: %s", event.Reason, event.Message))
+	}
+	return events
+}
+
+// ListPods returns the warp Pods in the namespace, or in all namespaces if the namespace is empty
+func (c *Client) ListPods(namespace string) ([]apiv1.Pod, error) {
+	client, err := c.getClient(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := client.List(metav1.ListOptions{LabelSelector: WarpSelector})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// WaitForPod watches the given pod until the exitCondition is true. Returns TimeoutError if the
+// condition is not true within the client timeout.
 func (c *Client) WaitForPod(namespace, name string, exitCondition watchtools.ConditionFunc) (*apiv1.Pod, error) {
+	pod, err := c.waitForPod(namespace, name, exitCondition, c.timeout)
+	if err == wait.ErrWaitTimeout {
+		return pod, &TimeoutError{Phase: "the Pod to start", Timeout: c.timeout}
+	}
+	return pod, err
+}
+
+// ExitCode waits until the container terminates and returns its exit code
+func (c *Client) ExitCode(namespace, name, containerName string) (int, error) {
+	// The container should be terminated already when the attach returns, so wait only for the status update
+	pod, err := c.waitForPod(namespace, name, ContainerTerminated(containerName), 30*time.Second)
+	if err == wait.ErrWaitTimeout {
+		return 0, &TimeoutError{Phase: "the container to terminate", Timeout: 30 * time.Second}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return ContainerExitCode(pod, containerName)
+}
+
+func (c *Client) waitForPod(namespace, name string, exitCondition watchtools.ConditionFunc, timeout time.Duration) (*apiv1.Pod, error) {
 	client, err := c.getClient(namespace)
 	if err != nil {
 		return nil, err
@@ -85,8 +223,7 @@ func (c *Client) WaitForPod(namespace, name string, exitCondition watchtools.Con
 		return nil, err
 	}
 
-	// TODO: expose the timeout
-	ctx, cancel := watchtools.ContextWithOptionalTimeout(context.Background(), 0*time.Second)
+	ctx, cancel := watchtools.ContextWithOptionalTimeout(context.Background(), timeout)
 	defer cancel()
 	intr := interrupt.New(nil, cancel)
 	var result *apiv1.Pod
@@ -105,6 +242,10 @@ func (c *Client) WaitForPod(namespace, name string, exitCondition watchtools.Con
 		err = errors.NewNotFound(apiv1.Resource("pods"), name)
 	}
 
+	if startupErr, ok := err.(*PodStartupError); ok {
+		startupErr.Events = c.getEvents(namespace, name)
+	}
+
 	return result, err
 }
 
@@ -180,6 +321,12 @@ func getTerminal(stdin io.Reader, stdout io.Writer) (term.TTY, remotecommand.Ter
 	return t, sizeQueue
 }
 
+// FindContainer returns reference to the container in the Pod spec, given by name
+// or the first container if name is empty.
+func FindContainer(pod *apiv1.Pod, containerName string) (*apiv1.Container, error) {
+	return containerToAttachTo(pod, containerName)
+}
+
 // containerToAttach returns a reference to the container to attach to, given
 // by name or the first container if name is empty.
 func containerToAttachTo(pod *apiv1.Pod, containerName string) (*apiv1.Container, error) {
@@ -200,15 +347,13 @@ func containerToAttachTo(pod *apiv1.Pod, containerName string) (*apiv1.Container
 	return &pod.Spec.Containers[0], nil
 }
 
-func (c *Client) createSSHSecret(namespace, name string, publicKey []byte) error {
-	c.deleteSSHSecret(namespace, name)
-
+func (c *Client) createSSHSecret(namespace, name string, opts PodOptions, owner *apiv1.Pod, keys SSHKeys) error {
 	clientset, err := kubernetes.NewForConfig(c.config)
 	if err != nil {
 		return err
 	}
 
-	_, err = clientset.CoreV1().Secrets(namespace).Create(createSecretManifest(name, publicKey))
+	_, err = clientset.CoreV1().Secrets(namespace).Create(createSecretManifest(name, opts, owner, keys))
 	if err != nil {
 		return err
 	}
@@ -216,7 +361,100 @@ func (c *Client) createSSHSecret(namespace, name string, publicKey []byte) error
 	return nil
 }
 
-func (c *Client) deleteSSHSecret(namespace, name string) error {
+// GetSSHKeys returns the keys what were generated for the Pod. The client private key is not stored, so
+// the PrivateKey is always empty.
+func (c *Client) GetSSHKeys(namespace, name string) (SSHKeys, error) {
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return SSHKeys{}, err
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return SSHKeys{}, err
+	}
+
+	hostPublicKey, ok := secret.Data[hostPublicKeyKey]
+	if !ok {
+		return SSHKeys{}, ErrWithMessagef(ErrNotFound, "Host key not found from Secret %s", name)
+	}
+	return SSHKeys{
+		PublicKey:      secret.Data[authorizedKeysKey],
+		HostPublicKey:  hostPublicKey,
+		HostPrivateKey: secret.Data[hostKeyKey],
+	}, nil
+}
+
+// UpdateAuthorizedKey replaces the authorized client public key in the Pod Secret. The running sync
+// container sees the new key after the kubelet updates the Secret volume.
+func (c *Client) UpdateAuthorizedKey(namespace, name string, publicKey []byte) error {
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return err
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"data": map[string][]byte{
+			authorizedKeysKey: publicKey,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = clientset.CoreV1().Secrets(namespace).Patch(name, types.MergePatchType, patch)
+	return err
+}
+
+// ListSSHSecrets returns the warp Secrets in the namespace, or in all namespaces if the namespace is empty
+func (c *Client) ListSSHSecrets(namespace string) ([]apiv1.Secret, error) {
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := clientset.CoreV1().Secrets(namespace).List(metav1.ListOptions{LabelSelector: WarpSelector})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// deleteLeftoverSecret deletes the warp Secret what is left from the previous Pod with the same name, e.g.
+// when the garbage collector hasn't removed it yet
+func (c *Client) deleteLeftoverSecret(namespace, name string) error {
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return err
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	pod, err := clientset.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		pod = nil
+	} else if err != nil {
+		return err
+	}
+
+	if !isLeftoverSecret(*secret, pod) {
+		return nil
+	}
+	err = clientset.CoreV1().Secrets(namespace).Delete(name, &metav1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// DeleteSSHSecret deletes the Secret what holds the Pod SSH keys
+func (c *Client) DeleteSSHSecret(namespace, name string) error {
 	clientset, err := kubernetes.NewForConfig(c.config)
 	if err != nil {
 		return err
@@ -225,6 +463,35 @@ func (c *Client) deleteSSHSecret(namespace, name string) error {
 	return clientset.CoreV1().Secrets(namespace).Delete(name, &metav1.DeleteOptions{})
 }
 
+// AnnotatePod sets the annotations to the Pod. Annotations with empty value get removed.
+func (c *Client) AnnotatePod(namespace, name string, annotations map[string]string) error {
+	client, err := c.getClient(namespace)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]interface{}{}
+	for key, value := range annotations {
+		if value == "" {
+			values[key] = nil
+		} else {
+			values[key] = value
+		}
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": values,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Patch(name, types.MergePatchType, patch)
+	return err
+}
+
 func (c *Client) DeletePod(namespace, name string) error {
 	client, err := c.getClient(namespace)
 	if err != nil {
@@ -3,6 +3,7 @@ package kubectl
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -26,10 +27,16 @@ func PodInitReady(event watch.Event) (bool, error) {
 	case *apiv1.Pod:
 		switch t.Status.Phase {
 		case apiv1.PodFailed, apiv1.PodSucceeded:
+			if err := initContainerError(t); err != nil {
+				return false, err
+			}
 			return false, ErrPodCompleted
 		case apiv1.PodRunning:
 			return false, ErrPodStarted
 		case apiv1.PodPending:
+			if err := podStartupError(t); err != nil {
+				return false, err
+			}
 			return isInitContainersReady(t), nil
 		}
 	}
@@ -60,8 +67,14 @@ func isInitContainersRunning(pod *apiv1.Pod) bool {
 		if len(pod.Spec.InitContainers) != len(pod.Status.InitContainerStatuses) {
 			return false
 		}
-		for _, status := range pod.Status.InitContainerStatuses {
-			if status.State.Running == nil {
+		// Init containers run in order, e.g. the ones from the --from Pod before the sync-init,
+		// so the others must have completed
+		for i, status := range pod.Status.InitContainerStatuses {
+			last := i == len(pod.Status.InitContainerStatuses)-1
+			if last && status.State.Running == nil {
+				return false
+			}
+			if !last && status.State.Running == nil && (status.State.Terminated == nil || status.State.Terminated.ExitCode != 0) {
 				return false
 			}
 		}
@@ -84,8 +97,13 @@ func ContainerRunning(containerName string) func(watch.Event) (bool, error) {
 			switch t.Status.Phase {
 			case apiv1.PodFailed, apiv1.PodSucceeded:
 				return false, ErrPodCompleted
-			case apiv1.PodRunning:
-				return isContainerRunning(t, containerName)
+			case apiv1.PodPending, apiv1.PodRunning:
+				if err := podStartupError(t); err != nil {
+					return false, err
+				}
+				if t.Status.Phase == apiv1.PodRunning {
+					return isContainerRunning(t, containerName)
+				}
 			}
 		}
 		return false, nil
@@ -109,3 +127,124 @@ func isContainerRunning(pod *apiv1.Pod, containerName string) (bool, error) {
 	}
 	return false, ErrNoContainerFound
 }
+
+// ContainerTerminated returns true if the container has terminated, false if it's not yet terminated,
+// or an error if the pod has been deleted or failed without running the container.
+func ContainerTerminated(containerName string) func(watch.Event) (bool, error) {
+	return func(event watch.Event) (bool, error) {
+		switch event.Type {
+		case watch.Deleted:
+			return false, errors.NewNotFound(schema.GroupResource{Resource: "pods"}, "")
+		}
+		switch t := event.Object.(type) {
+		case *apiv1.Pod:
+			for _, status := range t.Status.ContainerStatuses {
+				if status.Name == containerName && status.State.Terminated != nil {
+					return true, nil
+				}
+			}
+			if t.Status.Phase == apiv1.PodFailed {
+				return false, fmt.Errorf("pod failed: %s %s", t.Status.Reason, t.Status.Message)
+			}
+		}
+		return false, nil
+	}
+}
+
+// ContainerExitCode returns the exit code of the terminated container
+func ContainerExitCode(pod *apiv1.Pod, containerName string) (int, error) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == containerName {
+			if status.State.Terminated == nil {
+				return 0, fmt.Errorf("container %s is not terminated", containerName)
+			}
+			return int(status.State.Terminated.ExitCode), nil
+		}
+	}
+	return 0, ErrNoContainerFound
+}
+
+// PodStartupError is returned when the Pod cannot start, e.g. the image cannot be pulled
+type PodStartupError struct {
+	Reason  string
+	Message string
+	// Container is the name of the container what cannot start, empty if the whole Pod cannot start
+	Container string
+	// Events are the Pod events what tell more about the reason
+	Events []string
+}
+
+func (e *PodStartupError) Error() string {
+	msg := fmt.Sprintf("Pod cannot start: %s", e.Reason)
+	if e.Container != "" {
+		msg = fmt.Sprintf("Container %s cannot start: %s", e.Container, e.Reason)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if hint, ok := startupErrorHints[e.Reason]; ok {
+		msg += "\n" + hint
+	}
+	if len(e.Events) > 0 {
+		msg += "\nEvents:\n  " + strings.Join(e.Events, "\n  ")
+	}
+	return msg
+}
+
+// startupErrorHints tell what to do when the Pod cannot start because of the reason
+var startupErrorHints = map[string]string{
+	"ErrImagePull":               "Check that the image exists and the cluster is allowed to pull it.",
+	"ImagePullBackOff":           "Check that the image exists and the cluster is allowed to pull it.",
+	"InvalidImageName":           "Check the image name.",
+	"CreateContainerConfigError": "Check that the ConfigMaps and Secrets what the Pod refers, e.g. with --env-from, exist.",
+	"CreateContainerError":       "Check the container command and configuration.",
+	"CrashLoopBackOff":           "The container keeps crashing, check its logs.",
+	"Unschedulable":              "Check the --requests, --node-selector, --node-affinity and --toleration options and the cluster capacity.",
+	InitContainerFailed:          "Check the container logs. The sync-check container fails if the --sync-image doesn't have the binaries what warp needs.",
+}
+
+// InitContainerFailed is the PodStartupError reason when an init container exits with non-zero exit code
+const InitContainerFailed = "InitContainerFailed"
+
+// podStartupError returns PodStartupError if the Pod or any of its containers is waiting for a reason
+// what doesn't resolve by waiting, or nil if the Pod is starting normally
+func podStartupError(pod *apiv1.Pod) error {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == apiv1.PodScheduled && condition.Status == apiv1.ConditionFalse && condition.Reason == apiv1.PodReasonUnschedulable {
+			return &PodStartupError{Reason: condition.Reason, Message: condition.Message}
+		}
+	}
+
+	if err := initContainerError(pod); err != nil {
+		return err
+	}
+
+	for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		if status.State.Waiting == nil {
+			continue
+		}
+		if _, ok := startupErrorHints[status.State.Waiting.Reason]; ok {
+			return &PodStartupError{
+				Reason:    status.State.Waiting.Reason,
+				Message:   status.State.Waiting.Message,
+				Container: status.Name,
+			}
+		}
+	}
+	return nil
+}
+
+// initContainerError returns PodStartupError if any of the init containers has failed, the Pod never
+// starts the containers then
+func initContainerError(pod *apiv1.Pod) error {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.State.Terminated != nil && status.State.Terminated.ExitCode != 0 {
+			return &PodStartupError{
+				Reason:    InitContainerFailed,
+				Message:   strings.TrimSpace(status.State.Terminated.Message),
+				Container: status.Name,
+			}
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
 )
 
 func TestIsInitContainersReady(t *testing.T) {
@@ -42,3 +43,123 @@ func TestIsInitContainersReady(t *testing.T) {
 
 	require.True(t, isInitContainersReady(pod))
 }
+
+func TestIsInitContainersReadyAfterBaseInitContainers(t *testing.T) {
+	pod := &apiv1.Pod{
+		Spec: apiv1.PodSpec{
+			InitContainers: []apiv1.Container{{Name: "migrate"}, {Name: "sync-init"}},
+		},
+		Status: apiv1.PodStatus{
+			Phase:      apiv1.PodPending,
+			Conditions: []apiv1.PodCondition{{Type: apiv1.PodScheduled, Status: apiv1.ConditionTrue}},
+			InitContainerStatuses: []apiv1.ContainerStatus{
+				{Name: "migrate", State: apiv1.ContainerState{Running: &apiv1.ContainerStateRunning{}}},
+				{Name: "sync-init", State: apiv1.ContainerState{Waiting: &apiv1.ContainerStateWaiting{}}},
+			},
+		},
+	}
+	require.False(t, isInitContainersReady(pod))
+
+	pod.Status.InitContainerStatuses[0].State = apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 0}}
+	pod.Status.InitContainerStatuses[1].State = apiv1.ContainerState{Running: &apiv1.ContainerStateRunning{}}
+	require.True(t, isInitContainersReady(pod))
+}
+
+func TestContainerTerminated(t *testing.T) {
+	pod := &apiv1.Pod{
+		Status: apiv1.PodStatus{
+			Phase: apiv1.PodRunning,
+			ContainerStatuses: []apiv1.ContainerStatus{
+				{
+					Name:  "sync",
+					State: apiv1.ContainerState{Running: &apiv1.ContainerStateRunning{}},
+				},
+				{
+					Name:  "exec",
+					State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 3}},
+				},
+			},
+		},
+	}
+
+	done, err := ContainerTerminated("exec")(watch.Event{Type: watch.Modified, Object: pod})
+	require.NoError(t, err)
+	require.True(t, done)
+
+	done, err = ContainerTerminated("sync")(watch.Event{Type: watch.Modified, Object: pod})
+	require.NoError(t, err)
+	require.False(t, done)
+
+	code, err := ContainerExitCode(pod, "exec")
+	require.NoError(t, err)
+	require.Equal(t, 3, code)
+
+	_, err = ContainerExitCode(pod, "sync")
+	require.Error(t, err)
+}
+
+func TestPodStartupError(t *testing.T) {
+	pod := &apiv1.Pod{
+		Status: apiv1.PodStatus{
+			Phase: apiv1.PodPending,
+			InitContainerStatuses: []apiv1.ContainerStatus{
+				{
+					Name: "sync-init",
+					State: apiv1.ContainerState{Waiting: &apiv1.ContainerStateWaiting{
+						Reason:  "ImagePullBackOff",
+						Message: `Back-off pulling image "nosuchimage"`,
+					}},
+				},
+			},
+		},
+	}
+
+	_, err := PodInitReady(watch.Event{Type: watch.Modified, Object: pod})
+	require.Error(t, err)
+	startupErr, ok := err.(*PodStartupError)
+	require.True(t, ok)
+	require.Equal(t, "sync-init", startupErr.Container)
+
+	startupErr.Events = []string{"Failed: Failed to pull image"}
+	require.Contains(t, startupErr.Error(), "Container sync-init cannot start: ImagePullBackOff")
+	require.Contains(t, startupErr.Error(), "Check that the image exists")
+	require.Contains(t, startupErr.Error(), "Failed: Failed to pull image")
+
+	pod.Status.InitContainerStatuses[0].State.Waiting.Reason = "PodInitializing"
+	require.NoError(t, podStartupError(pod))
+
+	pod.Status.Conditions = []apiv1.PodCondition{{
+		Type:    apiv1.PodScheduled,
+		Status:  apiv1.ConditionFalse,
+		Reason:  apiv1.PodReasonUnschedulable,
+		Message: "0/3 nodes are available: 3 Insufficient cpu.",
+	}}
+	_, err = ContainerRunning("exec")(watch.Event{Type: watch.Modified, Object: pod})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Insufficient cpu")
+}
+
+func TestPodStartupErrorInitContainerFailed(t *testing.T) {
+	pod := &apiv1.Pod{
+		Status: apiv1.PodStatus{
+			Phase: apiv1.PodFailed,
+			InitContainerStatuses: []apiv1.ContainerStatus{
+				{
+					Name: "sync-check",
+					State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{
+						ExitCode: 1,
+						Message:  "The sync image registry.local/sync doesn't have rsync\n",
+					}},
+				},
+			},
+		},
+	}
+
+	_, err := PodInitReady(watch.Event{Type: watch.Modified, Object: pod})
+	require.Error(t, err)
+	startupErr, ok := err.(*PodStartupError)
+	require.True(t, ok)
+	require.Equal(t, "sync-check", startupErr.Container)
+	require.Contains(t, startupErr.Error(), "doesn't have rsync")
+	require.Contains(t, startupErr.Error(), "--sync-image")
+}
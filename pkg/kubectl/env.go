@@ -0,0 +1,57 @@
+package kubectl
+
+import (
+	"fmt"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// ParseEnv parses the environment variables in format KEY=VALUE. Variable without the value, e.g. KEY,
+// gets the value with the lookup function, like the local environment. Later value of the same key
+// overrides the earlier one.
+func ParseEnv(values []string, lookup func(string) (string, bool)) ([]apiv1.EnvVar, error) {
+	env := []apiv1.EnvVar{}
+	index := map[string]int{}
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		name := parts[0]
+		if name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("Invalid environment variable %q, must be in format KEY=VALUE", value)
+		}
+
+		var v string
+		if len(parts) == 2 {
+			v = parts[1]
+		} else if found, ok := lookup(name); ok {
+			v = found
+		} else {
+			return nil, fmt.Errorf("Environment variable %s has no value and is not set locally", name)
+		}
+
+		if i, ok := index[name]; ok {
+			env[i].Value = v
+			continue
+		}
+		index[name] = len(env)
+		env = append(env, apiv1.EnvVar{Name: name, Value: v})
+	}
+	return env, nil
+}
+
+// ParseEnvFrom parses the environment source in format configmap/NAME or secret/NAME
+func ParseEnvFrom(value string) (apiv1.EnvFromSource, error) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return apiv1.EnvFromSource{}, fmt.Errorf("Invalid env-from %q, must be in format configmap/NAME or secret/NAME", value)
+	}
+
+	reference := apiv1.LocalObjectReference{Name: parts[1]}
+	switch strings.ToLower(parts[0]) {
+	case "configmap", "cm":
+		return apiv1.EnvFromSource{ConfigMapRef: &apiv1.ConfigMapEnvSource{LocalObjectReference: reference}}, nil
+	case "secret":
+		return apiv1.EnvFromSource{SecretRef: &apiv1.SecretEnvSource{LocalObjectReference: reference}}, nil
+	}
+	return apiv1.EnvFromSource{}, fmt.Errorf("Invalid env-from %q, must be in format configmap/NAME or secret/NAME", value)
+}
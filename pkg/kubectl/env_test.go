@@ -0,0 +1,46 @@
+package kubectl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestParseEnv(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "HOME" {
+			return "/home/erno", true
+		}
+		return "", false
+	}
+
+	env, err := ParseEnv([]string{"FOO=bar", "EMPTY=", "URL=http://host?a=b", "HOME", "FOO=baz"}, lookup)
+	require.NoError(t, err)
+	require.Equal(t, []apiv1.EnvVar{
+		{Name: "FOO", Value: "baz"},
+		{Name: "EMPTY", Value: ""},
+		{Name: "URL", Value: "http://host?a=b"},
+		{Name: "HOME", Value: "/home/erno"},
+	}, env)
+
+	_, err = ParseEnv([]string{"MISSING"}, lookup)
+	require.Error(t, err)
+	_, err = ParseEnv([]string{"=value"}, lookup)
+	require.Error(t, err)
+}
+
+func TestParseEnvFrom(t *testing.T) {
+	source, err := ParseEnvFrom("configmap/settings")
+	require.NoError(t, err)
+	require.Equal(t, "settings", source.ConfigMapRef.Name)
+
+	source, err = ParseEnvFrom("secret/credentials")
+	require.NoError(t, err)
+	require.Equal(t, "credentials", source.SecretRef.Name)
+
+	_, err = ParseEnvFrom("deployment/app")
+	require.Error(t, err)
+	_, err = ParseEnvFrom("secret")
+	require.Error(t, err)
+}
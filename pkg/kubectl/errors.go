@@ -2,6 +2,7 @@ package kubectl
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -20,3 +21,14 @@ func IsNotFound(err error) bool {
 func ErrWithMessagef(err error, format string, args ...interface{}) error {
 	return errors.WithMessage(err, fmt.Sprintf(format, args...))
 }
+
+// TimeoutError is returned when waiting something takes longer than the timeout
+type TimeoutError struct {
+	// Phase is what were waited, e.g. "the Pod to start"
+	Phase   string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("Timed out after %s waiting %s", e.Timeout, e.Phase)
+}
@@ -0,0 +1,134 @@
+package kubectl
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/kubernetes/pkg/kubectl/scheme"
+)
+
+// Transport is how the files get transferred to the Pod
+type Transport string
+
+const (
+	// SSHTransport syncs the files over SSH through the port forwarding to the sshd in the sync containers
+	SSHTransport Transport = "ssh"
+	// ExecTransport streams the files with tar through the pods/exec API, needs no port forwarding
+	ExecTransport Transport = "exec"
+)
+
+// ParseTransport validates the transport name
+func ParseTransport(value string) (Transport, error) {
+	switch t := Transport(value); t {
+	case SSHTransport, ExecTransport:
+		return t, nil
+	}
+	return "", fmt.Errorf("Invalid transport %q, must be ssh or exec", value)
+}
+
+// GetTransport returns the transport what the warp Pod were created for
+func GetTransport(pod *apiv1.Pod) Transport {
+	for _, container := range pod.Spec.Containers {
+		if container.Name != "sync" {
+			continue
+		}
+		for _, port := range container.Ports {
+			if port.Name == "ssh" {
+				return SSHTransport
+			}
+		}
+		return ExecTransport
+	}
+	return SSHTransport
+}
+
+// execTransport changes the sync containers to wait the files through the pods/exec API instead of
+// running sshd. The sync-init container completes when the SyncedMarker file gets created.
+func execTransport(pod *apiv1.Pod) {
+	for i := range pod.Spec.InitContainers {
+		if pod.Spec.InitContainers[i].Name == "sync-init" {
+			useExec(&pod.Spec.InitContainers[i], fmt.Sprintf("until [ -f %s ]; do sleep 1; done", SyncedMarker))
+		}
+	}
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == "sync" {
+			useExec(&pod.Spec.Containers[i], "trap 'exit 0' TERM; while true; do sleep 1; done")
+		}
+	}
+}
+
+func useExec(container *apiv1.Container, script string) {
+	container.Command = []string{"sh", "-c", script}
+	container.Args = nil
+	container.Env = nil
+	container.Ports = nil
+	container.ReadinessProbe = nil
+	container.LivenessProbe = nil
+}
+
+// Executor executes shell scripts in the Pod container through the pods/exec API
+type Executor struct {
+	config    *rest.Config
+	namespace string
+	name      string
+
+	mu        sync.Mutex
+	container string
+}
+
+// NewExecutor creates new executor what executes the scripts in the Pod container
+func (c *Client) NewExecutor(namespace, name, container string) *Executor {
+	return &Executor{
+		config:    c.config,
+		namespace: namespace,
+		name:      name,
+		container: container,
+	}
+}
+
+// UseContainer changes the container where the next scripts get executed
+func (e *Executor) UseContainer(container string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.container = container
+}
+
+// Execute runs the script in the container with sh
+func (e *Executor) Execute(script string, stdin io.Reader, stdout, stderr io.Writer) error {
+	e.mu.Lock()
+	container := e.container
+	e.mu.Unlock()
+
+	restClient, err := rest.UnversionedRESTClientFor(e.config)
+	if err != nil {
+		return err
+	}
+
+	req := restClient.Post().
+		Resource("pods").
+		Name(e.name).
+		Namespace(e.namespace).
+		SubResource("exec")
+	req.VersionedParams(&apiv1.PodExecOptions{
+		Container: container,
+		Command:   []string{"sh", "-c", script},
+		Stdin:     stdin != nil,
+		Stdout:    stdout != nil,
+		Stderr:    stderr != nil,
+	}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(e.config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+
+	return exec.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+}
@@ -0,0 +1,45 @@
+package kubectl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecTransportPodManifest(t *testing.T) {
+	pod := createPodManifest("test", PodOptions{
+		Image:     "alpine",
+		WorkDir:   "/work-dir",
+		Transport: ExecTransport,
+	})
+
+	require.Equal(t, ExecTransport, GetTransport(pod))
+
+	init := pod.Spec.InitContainers[1]
+	require.Equal(t, "sync-init", init.Name)
+	require.Empty(t, init.Ports)
+	require.Contains(t, init.Command[2], SyncedMarker)
+
+	require.Contains(t, pod.Spec.InitContainers[0].Command[2], "for b in sh tar;")
+
+	sync := pod.Spec.Containers[0]
+	require.Equal(t, "sync", sync.Name)
+	require.Empty(t, sync.Ports)
+	require.Nil(t, sync.ReadinessProbe)
+	require.Nil(t, sync.LivenessProbe)
+}
+
+func TestSSHTransportPodManifest(t *testing.T) {
+	pod := createPodManifest("test", PodOptions{Image: "alpine", WorkDir: "/work-dir"})
+
+	require.Equal(t, SSHTransport, GetTransport(pod))
+}
+
+func TestParseTransport(t *testing.T) {
+	transport, err := ParseTransport("exec")
+	require.NoError(t, err)
+	require.Equal(t, ExecTransport, transport)
+
+	_, err = ParseTransport("ftp")
+	require.Error(t, err)
+}
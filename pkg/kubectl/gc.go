@@ -0,0 +1,78 @@
+package kubectl
+
+import (
+	"fmt"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// StaleReason tells why the warp Pod is stale and can be removed, or empty string if the Pod is still in use.
+// The Pod is stale if it's completed, older than maxAge or nobody is connected to it and it's not detached
+// on purpose. Zero maxAge or heartbeatTimeout disables the check.
+func StaleReason(pod apiv1.Pod, now time.Time, maxAge, heartbeatTimeout time.Duration) string {
+	if pod.DeletionTimestamp != nil {
+		return ""
+	}
+
+	if pod.Status.Phase == apiv1.PodSucceeded || pod.Status.Phase == apiv1.PodFailed {
+		return fmt.Sprintf("Pod is %s", pod.Status.Phase)
+	}
+
+	age := now.Sub(pod.CreationTimestamp.Time)
+	if maxAge > 0 && age > maxAge {
+		return fmt.Sprintf("older than %s", duration.HumanDuration(maxAge))
+	}
+
+	if heartbeatTimeout <= 0 || pod.Annotations[DetachedAnnotation] == "true" {
+		return ""
+	}
+
+	// Pods created by the older versions don't have the heartbeat, so use the creation time
+	lastSeen := pod.CreationTimestamp.Time
+	if t, err := time.Parse(time.RFC3339, pod.Annotations[HeartbeatAnnotation]); err == nil {
+		lastSeen = t
+	}
+	if since := now.Sub(lastSeen); since > heartbeatTimeout {
+		return fmt.Sprintf("no heartbeat in %s", duration.HumanDuration(since))
+	}
+	return ""
+}
+
+// isLeftoverSecret tells if the warp Secret is left from the previous Pod, so it's not owned by the live Pod
+// with the same name. The Secrets without owner are kept if the Pod exists, because then it cannot be known.
+func isLeftoverSecret(secret apiv1.Secret, pod *apiv1.Pod) bool {
+	if secret.Labels[ManagedByLabel] != ManagedByWarp {
+		return false
+	}
+	if pod == nil {
+		return true
+	}
+	if len(secret.OwnerReferences) == 0 {
+		return false
+	}
+	for _, owner := range secret.OwnerReferences {
+		if owner.UID == pod.UID {
+			return false
+		}
+	}
+	return true
+}
+
+// VolumeStaleReason tells why the PersistentVolumeClaim what no Pod uses can be removed, or empty string if
+// it has been used within maxUnused. Zero maxUnused keeps the volumes forever.
+func VolumeStaleReason(claim apiv1.PersistentVolumeClaim, now time.Time, maxUnused time.Duration) string {
+	if claim.DeletionTimestamp != nil || maxUnused <= 0 {
+		return ""
+	}
+
+	lastUsed := claim.CreationTimestamp.Time
+	if t, err := time.Parse(time.RFC3339, claim.Annotations[LastUsedAnnotation]); err == nil {
+		lastUsed = t
+	}
+	if since := now.Sub(lastUsed); since > maxUnused {
+		return fmt.Sprintf("not used in %s", duration.HumanDuration(since))
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package kubectl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestStaleReason(t *testing.T) {
+	now := time.Now()
+	newPod := func(age time.Duration, annotations map[string]string) apiv1.Pod {
+		return apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+				Annotations:       annotations,
+			},
+			Status: apiv1.PodStatus{Phase: apiv1.PodRunning},
+		}
+	}
+	heartbeat := func(ago time.Duration) string {
+		return now.Add(-ago).UTC().Format(time.RFC3339)
+	}
+
+	require.Empty(t, StaleReason(newPod(time.Hour, map[string]string{HeartbeatAnnotation: heartbeat(time.Minute)}), now, 24*time.Hour, 5*time.Minute))
+	require.NotEmpty(t, StaleReason(newPod(time.Hour, map[string]string{HeartbeatAnnotation: heartbeat(time.Hour)}), now, 24*time.Hour, 5*time.Minute))
+	require.NotEmpty(t, StaleReason(newPod(time.Hour, nil), now, 24*time.Hour, 5*time.Minute))
+	require.Empty(t, StaleReason(newPod(time.Hour, map[string]string{DetachedAnnotation: "true"}), now, 24*time.Hour, 5*time.Minute))
+	require.NotEmpty(t, StaleReason(newPod(48*time.Hour, map[string]string{DetachedAnnotation: "true"}), now, 24*time.Hour, 5*time.Minute))
+	require.Empty(t, StaleReason(newPod(48*time.Hour, map[string]string{DetachedAnnotation: "true"}), now, 0, 5*time.Minute))
+
+	completed := newPod(time.Minute, map[string]string{HeartbeatAnnotation: heartbeat(0)})
+	completed.Status.Phase = apiv1.PodSucceeded
+	require.NotEmpty(t, StaleReason(completed, now, 24*time.Hour, 5*time.Minute))
+}
+
+func TestVolumeStaleReason(t *testing.T) {
+	now := time.Now()
+	claim := apiv1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			CreationTimestamp: metav1.NewTime(now.Add(-30 * 24 * time.Hour)),
+			Annotations:       map[string]string{LastUsedAnnotation: now.Add(-time.Hour).UTC().Format(time.RFC3339)},
+		},
+	}
+
+	require.Empty(t, VolumeStaleReason(claim, now, 7*24*time.Hour))
+	require.NotEmpty(t, VolumeStaleReason(claim, now, 30*time.Minute))
+	require.Empty(t, VolumeStaleReason(claim, now, 0))
+
+	delete(claim.Annotations, LastUsedAnnotation)
+	require.NotEmpty(t, VolumeStaleReason(claim, now, 7*24*time.Hour))
+}
+
+func TestIsLeftoverSecret(t *testing.T) {
+	pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", UID: "live"}}
+	secret := func(labels map[string]string, owner types.UID) apiv1.Secret {
+		s := apiv1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test", Labels: labels}}
+		if owner != "" {
+			s.OwnerReferences = []metav1.OwnerReference{{Kind: "Pod", Name: "test", UID: owner}}
+		}
+		return s
+	}
+	warp := map[string]string{ManagedByLabel: ManagedByWarp}
+
+	require.False(t, isLeftoverSecret(secret(warp, "live"), pod))
+	require.True(t, isLeftoverSecret(secret(warp, "previous"), pod))
+	require.True(t, isLeftoverSecret(secret(warp, "previous"), nil))
+	require.True(t, isLeftoverSecret(secret(warp, ""), nil))
+	require.False(t, isLeftoverSecret(secret(warp, ""), pod))
+	require.False(t, isLeftoverSecret(secret(nil, ""), nil))
+}
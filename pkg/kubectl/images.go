@@ -0,0 +1,88 @@
+package kubectl
+
+import (
+	"fmt"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// DefaultSyncImage is the image for the sync containers, it has sshd, rsync and tar
+const DefaultSyncImage = "ernoaapa/sshd-rsync"
+
+// ParsePullPolicy validates the image pull policy, empty means the Kubernetes default
+func ParsePullPolicy(value string) (apiv1.PullPolicy, error) {
+	switch p := apiv1.PullPolicy(value); p {
+	case "", apiv1.PullAlways, apiv1.PullIfNotPresent, apiv1.PullNever:
+		return p, nil
+	}
+	return "", fmt.Errorf("Invalid image pull policy %q, must be one of Always, IfNotPresent or Never", value)
+}
+
+// syncBinaries returns the binaries what the sync image must have for the transport and the syncer.
+// The exec transport always uses the tar syncer.
+func syncBinaries(transport Transport, syncer string) []string {
+	if transport == ExecTransport {
+		return []string{"sh", "tar"}
+	}
+	if syncer == "tar" {
+		return []string{"sshd", "tar"}
+	}
+	return []string{"sshd", "rsync"}
+}
+
+// createSyncCheckContainer returns init container what fails with clear message if the sync image
+// doesn't have the binaries what warp needs, instead of the sync failing later with obscure error
+func createSyncCheckContainer(image string, transport Transport, syncer string) apiv1.Container {
+	binaries := strings.Join(syncBinaries(transport, syncer), " ")
+	script := fmt.Sprintf(`export PATH="$PATH:/usr/sbin:/usr/bin:/sbin:/bin"; for b in %s; do command -v "$b" >/dev/null || { echo "The sync image %s doesn't have $b, it must have: %s"; exit 1; }; done`,
+		binaries, image, binaries)
+
+	return apiv1.Container{
+		Name:                     "sync-check",
+		Image:                    image,
+		Command:                  []string{"sh", "-c", script},
+		Resources:                syncResources,
+		TerminationMessagePolicy: apiv1.TerminationMessageFallbackToLogsOnError,
+	}
+}
+
+// applyImagePull sets the image pull policy for the warp containers and adds the image pull secrets
+func applyImagePull(pod *apiv1.Pod, opts PodOptions) {
+	if opts.ImagePullPolicy != "" {
+		for i, container := range pod.Spec.InitContainers {
+			if isWarpContainer(container.Name) {
+				pod.Spec.InitContainers[i].ImagePullPolicy = opts.ImagePullPolicy
+			}
+		}
+		for i, container := range pod.Spec.Containers {
+			if isWarpContainer(container.Name) {
+				pod.Spec.Containers[i].ImagePullPolicy = opts.ImagePullPolicy
+			}
+		}
+	}
+
+	for _, name := range opts.ImagePullSecrets {
+		if !hasImagePullSecret(pod.Spec.ImagePullSecrets, name) {
+			pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, apiv1.LocalObjectReference{Name: name})
+		}
+	}
+}
+
+// isWarpContainer returns true for the containers what warp adds or runs the command in
+func isWarpContainer(name string) bool {
+	switch name {
+	case "sync-check", "sync-init", "sync", "exec":
+		return true
+	}
+	return false
+}
+
+func hasImagePullSecret(secrets []apiv1.LocalObjectReference, name string) bool {
+	for _, secret := range secrets {
+		if secret.Name == name {
+			return true
+		}
+	}
+	return false
+}
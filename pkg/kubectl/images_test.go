@@ -0,0 +1,49 @@
+package kubectl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestCreatePodManifestImages(t *testing.T) {
+	pod := createPodManifest("test", PodOptions{
+		Image:            "alpine",
+		WorkDir:          "/work-dir",
+		SyncImage:        "registry.local/sshd-rsync:1.0",
+		ImagePullPolicy:  apiv1.PullIfNotPresent,
+		ImagePullSecrets: []string{"registry", "registry"},
+	})
+
+	for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		if container.Name != "exec" {
+			require.Equal(t, "registry.local/sshd-rsync:1.0", container.Image, container.Name)
+		}
+		require.Equal(t, apiv1.PullIfNotPresent, container.ImagePullPolicy, container.Name)
+	}
+	require.Equal(t, []apiv1.LocalObjectReference{{Name: "registry"}}, pod.Spec.ImagePullSecrets)
+}
+
+func TestCreatePodManifestDefaultSyncImage(t *testing.T) {
+	pod := createPodManifest("test", PodOptions{Image: "alpine", WorkDir: "/work-dir"})
+
+	require.Equal(t, DefaultSyncImage, pod.Spec.Containers[0].Image)
+	require.Empty(t, pod.Spec.Containers[0].ImagePullPolicy)
+	require.Contains(t, pod.Spec.InitContainers[0].Command[2], "for b in sshd rsync;")
+}
+
+func TestSyncBinaries(t *testing.T) {
+	require.Equal(t, []string{"sshd", "rsync"}, syncBinaries(SSHTransport, "rsync"))
+	require.Equal(t, []string{"sshd", "tar"}, syncBinaries(SSHTransport, "tar"))
+	require.Equal(t, []string{"sh", "tar"}, syncBinaries(ExecTransport, "rsync"))
+}
+
+func TestParsePullPolicy(t *testing.T) {
+	policy, err := ParsePullPolicy("Always")
+	require.NoError(t, err)
+	require.Equal(t, apiv1.PullAlways, policy)
+
+	_, err = ParsePullPolicy("always")
+	require.Error(t, err)
+}
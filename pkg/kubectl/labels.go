@@ -0,0 +1,51 @@
+package kubectl
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Labels and annotations what warp adds to the resources it creates
+const (
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ManagedByWarp  = "kubectl-warp"
+	OwnerLabel     = "warp.ernoaapa.github.io/owner"
+	// SwappedLabel marks the Deployments what are swapped to the warp Pod
+	SwappedLabel = "warp.ernoaapa.github.io/swapped"
+
+	OwnerAnnotation     = "warp.ernoaapa.github.io/owner"
+	HostnameAnnotation  = "warp.ernoaapa.github.io/hostname"
+	SourceDirAnnotation = "warp.ernoaapa.github.io/source-dir"
+	ImageAnnotation     = "warp.ernoaapa.github.io/image"
+	StartTimeAnnotation = "warp.ernoaapa.github.io/start-time"
+	// SyncDirsAnnotation is the JSON list of the local and remote directories what the Pod syncs
+	SyncDirsAnnotation = "warp.ernoaapa.github.io/sync-dirs"
+	// HeartbeatAnnotation is updated periodically while warp is connected to the Pod
+	HeartbeatAnnotation = "warp.ernoaapa.github.io/heartbeat"
+	// DetachedAnnotation marks the Pods what are left running on purpose
+	DetachedAnnotation = "warp.ernoaapa.github.io/detached"
+	// SwappedDeploymentAnnotation is the name of the Deployment what the warp Pod replaces
+	SwappedDeploymentAnnotation = "warp.ernoaapa.github.io/swapped-deployment"
+	// SwappedPodAnnotation is the name of the warp Pod what replaces the Deployment
+	SwappedPodAnnotation = "warp.ernoaapa.github.io/swapped-pod"
+	// LastUsedAnnotation is the time when the PersistentVolumeClaim were used last time
+	LastUsedAnnotation = "warp.ernoaapa.github.io/last-used"
+	// OriginalReplicasAnnotation is the Deployment replica count before swapping
+	OriginalReplicasAnnotation = "warp.ernoaapa.github.io/original-replicas"
+)
+
+// WarpSelector is the label selector what matches all resources what warp have created
+var WarpSelector = ManagedByLabel + "=" + ManagedByWarp
+
+var invalidLabelChars = regexp.MustCompile(`[^-A-Za-z0-9_.]`)
+
+// SanitizeLabelValue converts the value to valid label value by replacing the invalid characters
+// and truncating it to the maximum length
+func SanitizeLabelValue(value string) string {
+	value = invalidLabelChars.ReplaceAllString(value, "_")
+	if len(value) > 63 {
+		value = value[:63]
+	}
+	// Must start and end with alphanumeric character
+	return strings.Trim(value, "-_.")
+}
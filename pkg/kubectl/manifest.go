@@ -1,28 +1,176 @@
 package kubectl
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
 	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 )
 
 var mode = int32(256)
 
-func createSecretManifest(name string, publicKey []byte) *apiv1.Secret {
-	return &apiv1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: name,
+// PodOptions are the settings for the warp Pod
+type PodOptions struct {
+	Image   string
+	Command []string
+	// WorkDir is the working directory for the command
+	WorkDir string
+	// SyncDirs are the directories where the files are synced, each in its own volume.
+	// Defaults to the WorkDir.
+	SyncDirs           []string
+	TTY                bool
+	Stdin              bool
+	ServiceAccountName string
+	NodeSelector       map[string]string
+	Tolerations        []apiv1.Toleration
+	Affinity           *apiv1.Affinity
+	NodeName           string
+	PriorityClassName  string
+	// Env and EnvFrom are the environment variables for the exec container
+	Env     []apiv1.EnvVar
+	EnvFrom []apiv1.EnvFromSource
+	// Requests and Limits are the resources for the exec container
+	Requests apiv1.ResourceList
+	Limits   apiv1.ResourceList
+	// Ports are the ports what the command listens in the exec container
+	Ports []PortMapping
+	// ActiveDeadline is the maximum time the Pod can run, zero means no limit
+	ActiveDeadline time.Duration
+	// Persistent keeps the working directory in a PersistentVolumeClaim between the sessions and
+	// Caches are the paths in the exec container what are kept in their own PersistentVolumeClaims
+	Persistent   bool
+	Caches       []string
+	StorageClass string
+	VolumeSize   resource.Quantity
+	// Base is the Pod spec what the warp Pod is based on, e.g. from a Deployment, and BaseContainer is
+	// the container in it what runs the command, the first container if empty
+	Base          *apiv1.PodSpec
+	BaseContainer string
+	// SyncImage is the image for the sync containers, defaults to DefaultSyncImage
+	SyncImage string
+	// ImagePullPolicy is set to all warp containers if not empty, and ImagePullSecrets are added to the Pod
+	ImagePullPolicy  apiv1.PullPolicy
+	ImagePullSecrets []string
+	// Transport is how the files get synced, ExecTransport replaces the sshd in the sync containers
+	Transport Transport
+	// Syncer is the --syncer what syncs the files with the SSHTransport, rsync if empty
+	Syncer string
+	// SecurityProfile selects the security settings, RestrictedSecurityProfile runs all containers as non-root
+	SecurityProfile SecurityProfile
+	// Patches are strategic merge patches in JSON format what are applied in order over the generated Pod
+	Patches [][]byte
+	// Labels and Annotations are added to the Pod and the Secret
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+const (
+	// authorizedKeysKey is the key for the client public key, attach replaces it with the new session key
+	authorizedKeysKey = "authorized_keys"
+	// hostKeyKey is the key for the sshd host private key, the name is the default sshd HostKey file name
+	hostKeyKey = "ssh_host_ecdsa_key"
+	// hostPublicKeyKey is the key for the sshd host public key what the client verifies the connection with
+	hostPublicKeyKey = "ssh_host_ecdsa_key.pub"
+)
+
+// SSHKeys are the keys for the SSH connection to the Pod
+type SSHKeys struct {
+	// PublicKey and PrivateKey are the client key pair, the PublicKey is authorized in the Pod and the
+	// PrivateKey never leaves the client
+	PublicKey  []byte
+	PrivateKey []byte
+	// HostPublicKey and HostPrivateKey are the Pod sshd host key pair, the client accepts only the HostPublicKey
+	HostPublicKey  []byte
+	HostPrivateKey []byte
+}
+
+func createSecretManifest(name string, opts PodOptions, owner *apiv1.Pod, keys SSHKeys) *apiv1.Secret {
+	meta := createObjectMeta(name, opts)
+	meta.OwnerReferences = []metav1.OwnerReference{
+		{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Name:       owner.Name,
+			UID:        owner.UID,
 		},
-		StringData: map[string]string{
-			"authorized_keys": string(publicKey),
+	}
+
+	data := map[string]string{
+		authorizedKeysKey: string(keys.PublicKey),
+		hostKeyKey:        string(keys.HostPrivateKey),
+		hostPublicKeyKey:  string(keys.HostPublicKey),
+		sshdConfigKey:     sshdConfig(sshPort, rootUser, "/var/run/sshd.pid"),
+	}
+	if opts.SecurityProfile == RestrictedSecurityProfile {
+		data[sshdConfigKey] = sshdConfig(nonRootSSHPort, nonRootUser, nonRootHomeDir+"/sshd.pid")
+		data[passwdKey] = passwd
+	}
+
+	return &apiv1.Secret{
+		ObjectMeta: meta,
+		StringData: data,
+	}
+}
+
+func createObjectMeta(name string, opts PodOptions) metav1.ObjectMeta {
+	labels := map[string]string{}
+	for key, value := range opts.Labels {
+		labels[key] = value
+	}
+	// The labels copied from the swapped workload may have their own managed-by label
+	labels[ManagedByLabel] = ManagedByWarp
+
+	return metav1.ObjectMeta{
+		Name:        name,
+		Labels:      labels,
+		Annotations: opts.Annotations,
+	}
+}
+
+// sshMounts returns the mount for the Secret directory in the sync containers. The directory is mounted
+// without subPath, so the authorized_keys updates reach the running containers.
+func sshMounts() []apiv1.VolumeMount {
+	return []apiv1.VolumeMount{
+		{
+			Name:      "ssh-config",
+			MountPath: sshConfigDir,
 		},
 	}
 }
 
-func createPodManifest(name, image string, cmd []string, workDir string, tty, stdin bool, svcAccName string, nodeSelectors map[string]string) *apiv1.Pod {
+// SyncedMarker is the file what tells the sync-init container that the initial sync is done
+const SyncedMarker = "/tmp/warp-synced"
+
+// sshdCommand returns the command what runs sshd with the configuration from the Secret
+func sshdCommand() []string {
+	return []string{sshdPath, "-D", "-e", "-f", sshConfigDir + "/" + sshdConfigKey}
+}
+
+// syncInitCommand returns the command what runs sshd until the SyncedMarker file gets created, so the
+// client can sync all the directories before the sync-init container completes
+func syncInitCommand() []string {
+	return []string{"sh", "-c", fmt.Sprintf("%s & until [ -f %s ]; do sleep 1; done", strings.Join(sshdCommand(), " "), SyncedMarker)}
+}
+
+func createPodManifest(name string, opts PodOptions) *apiv1.Pod {
+	workDir := opts.WorkDir
+	syncMounts, syncVolumes := createSyncVolumes(name, opts)
+	syncImage := opts.SyncImage
+	if syncImage == "" {
+		syncImage = DefaultSyncImage
+	}
+
 	syncContainer := apiv1.Container{
-		Name:  "sync",
-		Image: "ernoaapa/sshd-rsync",
+		Name:    "sync",
+		Image:   syncImage,
+		Command: sshdCommand(),
 		Ports: []apiv1.ContainerPort{
 			{
 				Name:          "ssh",
@@ -44,49 +192,80 @@ func createPodManifest(name, image string, cmd []string, workDir string, tty, st
 				},
 			},
 		},
-		VolumeMounts: []apiv1.VolumeMount{
-			{
-				Name:      "ssh-config",
-				MountPath: "/root/.ssh/authorized_keys",
-				SubPath:   "authorized_keys",
-			},
-			{
-				Name:      "workdir",
-				MountPath: workDir,
+		Resources:    syncResources,
+		VolumeMounts: append(sshMounts(), syncMounts...),
+	}
+
+	var ports []apiv1.ContainerPort
+	declared := map[uint16]bool{}
+	for _, p := range opts.Ports {
+		if declared[p.Remote] {
+			continue
+		}
+		declared[p.Remote] = true
+		ports = append(ports, apiv1.ContainerPort{
+			Protocol:      apiv1.ProtocolTCP,
+			ContainerPort: int32(p.Remote),
+		})
+	}
+
+	var cacheMounts []apiv1.VolumeMount
+	var cacheVolumes []apiv1.Volume
+	for i, path := range opts.Caches {
+		cacheMounts = append(cacheMounts, apiv1.VolumeMount{
+			Name:      cacheVolumeName(i),
+			MountPath: path,
+		})
+		cacheVolumes = append(cacheVolumes, apiv1.Volume{
+			Name: cacheVolumeName(i),
+			VolumeSource: apiv1.VolumeSource{
+				PersistentVolumeClaim: &apiv1.PersistentVolumeClaimVolumeSource{ClaimName: CacheClaimName(name, path)},
 			},
-		},
+		})
 	}
 
 	runContainer := apiv1.Container{
 		Name:       "exec",
-		Image:      image,
-		Command:    cmd,
-		TTY:        tty,
-		Stdin:      stdin,
-		StdinOnce:  stdin,
+		Image:      opts.Image,
+		Command:    opts.Command,
+		TTY:        opts.TTY,
+		Stdin:      opts.Stdin,
+		StdinOnce:  opts.Stdin,
 		WorkingDir: workDir,
-
-		VolumeMounts: []apiv1.VolumeMount{
-			{
-				Name:      "workdir",
-				MountPath: workDir,
-			},
+		Ports:      ports,
+		Env:        opts.Env,
+		EnvFrom:    opts.EnvFrom,
+		Resources: apiv1.ResourceRequirements{
+			Requests: opts.Requests,
+			Limits:   opts.Limits,
 		},
+
+		VolumeMounts: append(append([]apiv1.VolumeMount{}, syncMounts...), cacheMounts...),
 	}
 
-	return &apiv1.Pod{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: name,
+	var activeDeadlineSeconds *int64
+	if opts.ActiveDeadline > 0 {
+		seconds := int64(opts.ActiveDeadline.Seconds())
+		activeDeadlineSeconds = &seconds
+	}
 
-		},
+	pod := &apiv1.Pod{
+		ObjectMeta: createObjectMeta(name, opts),
 		Spec: apiv1.PodSpec{
-			ServiceAccountName: svcAccName,
-			RestartPolicy: apiv1.RestartPolicyNever,
-			NodeSelector: nodeSelectors,
+			ServiceAccountName:    opts.ServiceAccountName,
+			RestartPolicy:         apiv1.RestartPolicyNever,
+			NodeSelector:          opts.NodeSelector,
+			Tolerations:           opts.Tolerations,
+			Affinity:              opts.Affinity,
+			NodeName:              opts.NodeName,
+			PriorityClassName:     opts.PriorityClassName,
+			ActiveDeadlineSeconds: activeDeadlineSeconds,
 			InitContainers: []apiv1.Container{
+				createSyncCheckContainer(syncImage, opts.Transport, opts.Syncer),
 				{
-					Name:  "sync-init",
-					Image: "ernoaapa/sshd-rsync",
+					Name:    "sync-init",
+					Image:   syncImage,
+					Command: syncInitCommand(),
 					Ports: []apiv1.ContainerPort{
 						{
 							Name:          "ssh",
@@ -94,23 +273,8 @@ func createPodManifest(name, image string, cmd []string, workDir string, tty, st
 							ContainerPort: 22,
 						},
 					},
-					Env: []apiv1.EnvVar{
-						{
-							Name:  "ONE_TIME",
-							Value: "true",
-						},
-					},
-					VolumeMounts: []apiv1.VolumeMount{
-						{
-							Name:      "ssh-config",
-							MountPath: "/root/.ssh/authorized_keys",
-							SubPath:   "authorized_keys",
-						},
-						{
-							Name:      "workdir",
-							MountPath: workDir,
-						},
-					},
+					Resources:    syncResources,
+					VolumeMounts: append(sshMounts(), syncMounts...),
 				},
 			},
 			Containers: []apiv1.Container{
@@ -127,13 +291,109 @@ func createPodManifest(name, image string, cmd []string, workDir string, tty, st
 						},
 					},
 				},
-				{
-					Name: "workdir",
-					VolumeSource: apiv1.VolumeSource{
-						EmptyDir: &apiv1.EmptyDirVolumeSource{},
-					},
-				},
 			},
 		},
 	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, syncVolumes...)
+	pod.Spec.Volumes = append(pod.Spec.Volumes, cacheVolumes...)
+
+	if opts.Base != nil {
+		applyBase(pod, opts.Base, opts)
+	}
+	annotateImage(pod)
+	if opts.SecurityProfile == RestrictedSecurityProfile {
+		restrictPod(pod)
+	}
+	if opts.Transport == ExecTransport {
+		execTransport(pod)
+	}
+	applyImagePull(pod, opts)
+	return pod
+}
+
+// annotateImage adds the image what the exec container runs to the Pod annotations. The image comes
+// from the base container if not given, so it's known only after the base is applied.
+func annotateImage(pod *apiv1.Pod) {
+	annotations := map[string]string{}
+	for key, value := range pod.Annotations {
+		annotations[key] = value
+	}
+	for _, container := range pod.Spec.Containers {
+		if container.Name == "exec" {
+			annotations[ImageAnnotation] = container.Image
+		}
+	}
+	pod.Annotations = annotations
+}
+
+// createSyncVolumes returns the volumes for the synced directories and the mounts for them
+func createSyncVolumes(name string, opts PodOptions) ([]apiv1.VolumeMount, []apiv1.Volume) {
+	mounts := []apiv1.VolumeMount{}
+	volumes := []apiv1.Volume{}
+	for i, dir := range syncDirs(opts) {
+		source := apiv1.VolumeSource{
+			EmptyDir: &apiv1.EmptyDirVolumeSource{},
+		}
+		if opts.Persistent {
+			source = apiv1.VolumeSource{
+				PersistentVolumeClaim: &apiv1.PersistentVolumeClaimVolumeSource{ClaimName: syncClaimName(name, i)},
+			}
+		}
+
+		mounts = append(mounts, apiv1.VolumeMount{
+			Name:      syncVolumeName(i),
+			MountPath: dir,
+		})
+		volumes = append(volumes, apiv1.Volume{
+			Name:         syncVolumeName(i),
+			VolumeSource: source,
+		})
+	}
+	return mounts, volumes
+}
+
+func syncDirs(opts PodOptions) []string {
+	if len(opts.SyncDirs) == 0 {
+		return []string{opts.WorkDir}
+	}
+	return opts.SyncDirs
+}
+
+// syncVolumeName returns the name of the Pod volume for the synced directory
+func syncVolumeName(i int) string {
+	if i == 0 {
+		return "workdir"
+	}
+	return fmt.Sprintf("workdir-%d", i)
+}
+
+// patchPodManifest applies the strategic merge patches over the Pod and returns the result in JSON.
+// The result is returned as JSON so the fields what this client doesn't know are kept as they are.
+func patchPodManifest(pod *apiv1.Pod, patches [][]byte) ([]byte, error) {
+	manifest, err := json.Marshal(pod)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, patch := range patches {
+		manifest, err = strategicpatch.StrategicMergePatch(manifest, patch, apiv1.Pod{})
+		if err != nil {
+			return nil, errors.Wrap(err, "Cannot apply the Pod overrides")
+		}
+	}
+
+	// Make sure the patches didn't break the warp Pod
+	result := &apiv1.Pod{}
+	if err := json.Unmarshal(manifest, result); err != nil {
+		return nil, errors.Wrap(err, "Invalid Pod overrides")
+	}
+	if result.Name != pod.Name || result.Namespace != pod.Namespace {
+		return nil, fmt.Errorf("Invalid Pod overrides: the Pod name and namespace cannot be changed")
+	}
+	for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		if _, err := FindContainer(result, container.Name); err != nil {
+			return nil, fmt.Errorf("Invalid Pod overrides: the %s container cannot be removed", container.Name)
+		}
+	}
+	return manifest, nil
 }
@@ -0,0 +1,132 @@
+package kubectl
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestCreatePodManifestMetadata(t *testing.T) {
+	pod := createPodManifest("test", PodOptions{
+		Image:       "alpine",
+		WorkDir:     "/work-dir",
+		Labels:      map[string]string{OwnerLabel: "erno"},
+		Annotations: map[string]string{HostnameAnnotation: "laptop"},
+	})
+
+	require.Equal(t, map[string]string{ManagedByLabel: ManagedByWarp, OwnerLabel: "erno"}, pod.Labels)
+	require.Equal(t, "laptop", pod.Annotations[HostnameAnnotation])
+	require.Equal(t, "alpine", pod.Annotations[ImageAnnotation])
+
+	pod.UID = "123"
+	secret := createSecretManifest("test", PodOptions{}, pod, SSHKeys{PublicKey: []byte("public"), PrivateKey: []byte("private")})
+	require.Equal(t, ManagedByWarp, secret.Labels[ManagedByLabel])
+	require.Equal(t, pod.UID, secret.OwnerReferences[0].UID)
+}
+
+func TestCreateObjectMetaKeepsManagedByLabel(t *testing.T) {
+	meta := createObjectMeta("test", PodOptions{
+		Labels: map[string]string{"app": "api", ManagedByLabel: "Helm"},
+	})
+	require.Equal(t, map[string]string{"app": "api", ManagedByLabel: ManagedByWarp}, meta.Labels)
+}
+
+func TestSanitizeLabelValue(t *testing.T) {
+	require.Equal(t, "DOMAIN_erno", SanitizeLabelValue(`DOMAIN\erno`))
+	require.Equal(t, "erno", SanitizeLabelValue("_erno."))
+}
+
+func TestPatchPodManifest(t *testing.T) {
+	pod := createPodManifest("test", PodOptions{Image: "alpine", WorkDir: "/work-dir"})
+
+	manifest, err := patchPodManifest(pod, [][]byte{
+		[]byte(`{"spec":{"containers":[{"name":"exec","securityContext":{"privileged":true}}]}}`),
+		[]byte(`{"spec":{"hostNetwork":true,"futureField":"kept"}}`),
+	})
+	require.NoError(t, err)
+	require.Contains(t, string(manifest), `"futureField":"kept"`)
+
+	result := &apiv1.Pod{}
+	require.NoError(t, json.Unmarshal(manifest, result))
+	require.True(t, result.Spec.HostNetwork)
+	container, err := FindContainer(result, "exec")
+	require.NoError(t, err)
+	require.Equal(t, "alpine", container.Image)
+	require.True(t, *container.SecurityContext.Privileged)
+	require.Len(t, result.Spec.Containers, 2)
+
+	_, err = patchPodManifest(pod, [][]byte{[]byte(`{"metadata":{"name":"other"}}`)})
+	require.Error(t, err)
+	_, err = patchPodManifest(pod, [][]byte{[]byte(`{"spec":{"containers":[{"name":"sync","$patch":"delete"}]}}`)})
+	require.Error(t, err)
+}
+
+func TestCreatePodManifestPersistentVolumes(t *testing.T) {
+	opts := PodOptions{
+		Image:      "node",
+		WorkDir:    "/work-dir",
+		Persistent: true,
+		Caches:     []string{"/root/.npm"},
+	}
+	pod := createPodManifest("test", opts)
+
+	require.Equal(t, []string{"test-workdir", "test-cache-root-npm-9a9bd570"}, UsedClaims(*pod))
+	require.Equal(t, []string{"test-workdir", "test-cache-root-npm-9a9bd570"}, claimNames("test", opts))
+	require.NotEqual(t, CacheClaimName("test", "/root/.npm"), CacheClaimName("test", "/root/npm"))
+
+	container, err := FindContainer(pod, "exec")
+	require.NoError(t, err)
+	require.Equal(t, "/root/.npm", container.VolumeMounts[1].MountPath)
+
+	claim := createPVCManifest("test-workdir", opts)
+	size := claim.Spec.Resources.Requests[apiv1.ResourceStorage]
+	require.Equal(t, "10Gi", size.String())
+	require.Nil(t, claim.Spec.StorageClassName)
+}
+
+func TestCreatePodManifestSyncDirs(t *testing.T) {
+	opts := PodOptions{
+		Image:      "golang",
+		WorkDir:    "/app",
+		SyncDirs:   []string{"/app", "/libs"},
+		Persistent: true,
+	}
+	pod := createPodManifest("test", opts)
+
+	require.Equal(t, []string{"test-workdir", "test-workdir-1"}, UsedClaims(*pod))
+	for _, name := range []string{"sync-init", "sync", "exec"} {
+		container, err := FindContainer(pod, name)
+		require.NoError(t, err)
+		require.Contains(t, container.VolumeMounts, apiv1.VolumeMount{Name: "workdir", MountPath: "/app"})
+		require.Contains(t, container.VolumeMounts, apiv1.VolumeMount{Name: "workdir-1", MountPath: "/libs"})
+	}
+
+	exec, err := FindContainer(pod, "exec")
+	require.NoError(t, err)
+	require.Equal(t, "/app", exec.WorkingDir)
+
+	syncInit, err := FindContainer(pod, "sync-init")
+	require.NoError(t, err)
+	require.Contains(t, strings.Join(syncInit.Command, " "), SyncedMarker)
+}
+
+func TestSSHSecretIsMountedWithoutPrivateKey(t *testing.T) {
+	pod := createPodManifest("test", PodOptions{Image: "alpine", WorkDir: "/work-dir"})
+	secret := createSecretManifest("test", PodOptions{}, pod, SSHKeys{PublicKey: []byte("public"), PrivateKey: []byte("private")})
+
+	for key, value := range secret.StringData {
+		require.NotEqual(t, "private", value, key)
+	}
+	require.Contains(t, secret.StringData[sshdConfigKey], "AuthorizedKeysFile /etc/warp/authorized_keys")
+
+	for _, container := range []apiv1.Container{pod.Spec.InitContainers[1], pod.Spec.Containers[0]} {
+		require.Contains(t, strings.Join(container.Command, " "), sshdPath, container.Name)
+		require.Contains(t, container.VolumeMounts, apiv1.VolumeMount{Name: "ssh-config", MountPath: sshConfigDir}, container.Name)
+		for _, mount := range container.VolumeMounts {
+			require.Empty(t, mount.SubPath, container.Name)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package kubectl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PortMapping is a port forwarded from the local machine to the Pod
+type PortMapping struct {
+	Local  uint16
+	Remote uint16
+}
+
+// ParsePortMapping parses the port mapping in format LOCAL:REMOTE, or PORT if the ports are the same
+func ParsePortMapping(value string) (PortMapping, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) > 2 {
+		return PortMapping{}, fmt.Errorf("Invalid port %q, must be in format LOCAL:REMOTE", value)
+	}
+
+	ports := make([]uint16, len(parts))
+	for i, part := range parts {
+		port, err := strconv.ParseUint(part, 10, 16)
+		if err != nil || port == 0 {
+			return PortMapping{}, fmt.Errorf("Invalid port %q, must be in format LOCAL:REMOTE", value)
+		}
+		ports[i] = uint16(port)
+	}
+
+	return PortMapping{Local: ports[0], Remote: ports[len(ports)-1]}, nil
+}
+
+// String returns the mapping in the port-forwarder format LOCAL:REMOTE
+func (m PortMapping) String() string {
+	return fmt.Sprintf("%d:%d", m.Local, m.Remote)
+}
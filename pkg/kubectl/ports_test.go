@@ -0,0 +1,25 @@
+package kubectl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePortMapping(t *testing.T) {
+	m, err := ParsePortMapping("8080:80")
+	require.NoError(t, err)
+	require.Equal(t, PortMapping{Local: 8080, Remote: 80}, m)
+	require.Equal(t, "8080:80", m.String())
+
+	m, err = ParsePortMapping("3000")
+	require.NoError(t, err)
+	require.Equal(t, PortMapping{Local: 3000, Remote: 3000}, m)
+
+	_, err = ParsePortMapping("1:2:3")
+	require.Error(t, err)
+	_, err = ParsePortMapping("http")
+	require.Error(t, err)
+	_, err = ParsePortMapping("0:80")
+	require.Error(t, err)
+}
@@ -0,0 +1,83 @@
+package kubectl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// supportedResources are the resources what can be set with ParseResources
+var supportedResources = []apiv1.ResourceName{
+	apiv1.ResourceCPU,
+	apiv1.ResourceMemory,
+	apiv1.ResourceEphemeralStorage,
+}
+
+// syncResources are the resources for the sync containers. The limits are set so the Pod is accepted
+// in the namespaces where ResourceQuota requires the limits.
+var syncResources = apiv1.ResourceRequirements{
+	Requests: apiv1.ResourceList{
+		apiv1.ResourceCPU:    resource.MustParse("50m"),
+		apiv1.ResourceMemory: resource.MustParse("64Mi"),
+	},
+	Limits: apiv1.ResourceList{
+		apiv1.ResourceCPU:    resource.MustParse("1"),
+		apiv1.ResourceMemory: resource.MustParse("256Mi"),
+	},
+}
+
+// ParseResources parses the resource quantities, e.g. cpu=2 and memory=4Gi
+func ParseResources(values map[string]string) (apiv1.ResourceList, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	resources := apiv1.ResourceList{}
+	for name, value := range values {
+		if !isSupportedResource(apiv1.ResourceName(name)) {
+			return nil, fmt.Errorf("Unsupported resource %q, must be one of %s", name, supportedResourceNames())
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s quantity %q: %s", name, value, err)
+		}
+		resources[apiv1.ResourceName(name)] = quantity
+	}
+	return resources, nil
+}
+
+func isSupportedResource(name apiv1.ResourceName) bool {
+	for _, supported := range supportedResources {
+		if name == supported {
+			return true
+		}
+	}
+	return false
+}
+
+func supportedResourceNames() string {
+	names := make([]string, len(supportedResources))
+	for i, name := range supportedResources {
+		names[i] = string(name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// isResourceRejection returns true if the error is caused by ResourceQuota or LimitRange admission
+func isResourceRejection(err error) bool {
+	if !apierrors.IsForbidden(err) {
+		return false
+	}
+	message := err.Error()
+	for _, s := range []string{"quota", "per Container", "per Pod", "LimitRange"} {
+		if strings.Contains(message, s) {
+			return true
+		}
+	}
+	return false
+}
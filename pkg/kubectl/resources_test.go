@@ -0,0 +1,37 @@
+package kubectl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseResources(t *testing.T) {
+	resources, err := ParseResources(map[string]string{"cpu": "500m", "memory": "4Gi", "ephemeral-storage": "10Gi"})
+	require.NoError(t, err)
+	require.Equal(t, resource.MustParse("500m"), resources[apiv1.ResourceCPU])
+	require.Equal(t, resource.MustParse("4Gi"), resources[apiv1.ResourceMemory])
+	require.Equal(t, resource.MustParse("10Gi"), resources[apiv1.ResourceEphemeralStorage])
+
+	resources, err = ParseResources(map[string]string{})
+	require.NoError(t, err)
+	require.Nil(t, resources)
+
+	_, err = ParseResources(map[string]string{"gpu": "1"})
+	require.Error(t, err)
+	_, err = ParseResources(map[string]string{"memory": "lots"})
+	require.Error(t, err)
+}
+
+func TestIsResourceRejection(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	require.True(t, isResourceRejection(apierrors.NewForbidden(pods, "test", errors.New("exceeded quota: compute, requested: cpu=2, used: cpu=3, limited: cpu=4"))))
+	require.True(t, isResourceRejection(apierrors.NewForbidden(pods, "test", errors.New("maximum memory usage per Container is 1Gi, but limit is 2Gi"))))
+	require.False(t, isResourceRejection(apierrors.NewForbidden(pods, "test", errors.New("User cannot create pods"))))
+	require.False(t, isResourceRejection(errors.New("exceeded quota")))
+}
@@ -0,0 +1,94 @@
+package kubectl
+
+import (
+	"fmt"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// ParseToleration parses the toleration in format KEY[=VALUE][:EFFECT]. Without the value the toleration
+// matches any value of the taint and without the effect it matches all effects.
+func ParseToleration(value string) (apiv1.Toleration, error) {
+	toleration := apiv1.Toleration{Operator: apiv1.TolerationOpExists}
+
+	keyValue := value
+	if i := strings.LastIndex(value, ":"); i >= 0 {
+		keyValue = value[:i]
+		toleration.Effect = apiv1.TaintEffect(value[i+1:])
+		switch toleration.Effect {
+		case apiv1.TaintEffectNoSchedule, apiv1.TaintEffectPreferNoSchedule, apiv1.TaintEffectNoExecute:
+		default:
+			return apiv1.Toleration{}, fmt.Errorf("Invalid toleration %q, effect must be one of NoSchedule, PreferNoSchedule or NoExecute", value)
+		}
+	}
+
+	parts := strings.SplitN(keyValue, "=", 2)
+	toleration.Key = parts[0]
+	if toleration.Key == "" {
+		return apiv1.Toleration{}, fmt.Errorf("Invalid toleration %q, must be in format KEY[=VALUE][:EFFECT]", value)
+	}
+	if len(parts) == 2 {
+		toleration.Operator = apiv1.TolerationOpEqual
+		toleration.Value = parts[1]
+	}
+	return toleration, nil
+}
+
+// nodeSelectorOperators maps the label selector operators to the node selector operators
+var nodeSelectorOperators = map[selection.Operator]apiv1.NodeSelectorOperator{
+	selection.Equals:       apiv1.NodeSelectorOpIn,
+	selection.DoubleEquals: apiv1.NodeSelectorOpIn,
+	selection.In:           apiv1.NodeSelectorOpIn,
+	selection.NotEquals:    apiv1.NodeSelectorOpNotIn,
+	selection.NotIn:        apiv1.NodeSelectorOpNotIn,
+	selection.Exists:       apiv1.NodeSelectorOpExists,
+	selection.DoesNotExist: apiv1.NodeSelectorOpDoesNotExist,
+	selection.GreaterThan:  apiv1.NodeSelectorOpGt,
+	selection.LessThan:     apiv1.NodeSelectorOpLt,
+}
+
+// ParseNodeAffinity parses the node label selector expressions to the required node affinity,
+// e.g. "kubernetes.io/arch in (arm64,arm),pool!=spot". All requirements in one expression must match
+// and at least one of the expressions must match.
+func ParseNodeAffinity(expressions []string) (*apiv1.Affinity, error) {
+	if len(expressions) == 0 {
+		return nil, nil
+	}
+
+	terms := make([]apiv1.NodeSelectorTerm, len(expressions))
+	for i, expression := range expressions {
+		requirements, err := labels.ParseToRequirements(expression)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid node affinity %q: %s", expression, err)
+		}
+		if len(requirements) == 0 {
+			return nil, fmt.Errorf("Invalid node affinity %q: empty expression", expression)
+		}
+
+		for _, r := range requirements {
+			operator, ok := nodeSelectorOperators[r.Operator()]
+			if !ok {
+				return nil, fmt.Errorf("Invalid node affinity %q: unsupported operator %s", expression, r.Operator())
+			}
+			requirement := apiv1.NodeSelectorRequirement{
+				Key:      r.Key(),
+				Operator: operator,
+			}
+			if r.Values().Len() > 0 {
+				requirement.Values = r.Values().List()
+			}
+			terms[i].MatchExpressions = append(terms[i].MatchExpressions, requirement)
+		}
+	}
+
+	return &apiv1.Affinity{
+		NodeAffinity: &apiv1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &apiv1.NodeSelector{
+				NodeSelectorTerms: terms,
+			},
+		},
+	}, nil
+}
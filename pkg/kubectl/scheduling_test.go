@@ -0,0 +1,45 @@
+package kubectl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestParseToleration(t *testing.T) {
+	toleration, err := ParseToleration("dedicated=build:NoSchedule")
+	require.NoError(t, err)
+	require.Equal(t, apiv1.Toleration{Key: "dedicated", Operator: apiv1.TolerationOpEqual, Value: "build", Effect: apiv1.TaintEffectNoSchedule}, toleration)
+
+	toleration, err = ParseToleration("example.com/gpu")
+	require.NoError(t, err)
+	require.Equal(t, apiv1.Toleration{Key: "example.com/gpu", Operator: apiv1.TolerationOpExists}, toleration)
+
+	_, err = ParseToleration("dedicated:Never")
+	require.Error(t, err)
+	_, err = ParseToleration("=build")
+	require.Error(t, err)
+}
+
+func TestParseNodeAffinity(t *testing.T) {
+	affinity, err := ParseNodeAffinity([]string{"kubernetes.io/arch in (arm64,arm),pool!=spot", "dedicated"})
+	require.NoError(t, err)
+
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	require.Len(t, terms, 2)
+	require.Equal(t, []apiv1.NodeSelectorRequirement{
+		{Key: "kubernetes.io/arch", Operator: apiv1.NodeSelectorOpIn, Values: []string{"arm", "arm64"}},
+		{Key: "pool", Operator: apiv1.NodeSelectorOpNotIn, Values: []string{"spot"}},
+	}, terms[0].MatchExpressions)
+	require.Equal(t, []apiv1.NodeSelectorRequirement{
+		{Key: "dedicated", Operator: apiv1.NodeSelectorOpExists},
+	}, terms[1].MatchExpressions)
+
+	affinity, err = ParseNodeAffinity(nil)
+	require.NoError(t, err)
+	require.Nil(t, affinity)
+
+	_, err = ParseNodeAffinity([]string{"arch in arm64"})
+	require.Error(t, err)
+}
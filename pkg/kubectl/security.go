@@ -0,0 +1,188 @@
+package kubectl
+
+import (
+	"fmt"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// SecurityProfile selects the security settings of the warp Pod
+type SecurityProfile string
+
+const (
+	// DefaultSecurityProfile runs the sync containers as root and leaves the security settings to the image
+	DefaultSecurityProfile SecurityProfile = "default"
+	// RestrictedSecurityProfile runs all containers as non-root so the Pod passes the restricted
+	// Pod Security Standard
+	RestrictedSecurityProfile SecurityProfile = "restricted"
+)
+
+const (
+	// sshPort is the port where sshd listens as root
+	sshPort = 22
+	// nonRootSSHPort is the unprivileged port where sshd listens as non-root
+	nonRootSSHPort = 2222
+	// rootUser and nonRootUser are the users what the sync containers accept
+	rootUser    = "root"
+	nonRootUser = "warp"
+	// nonRootUID is the user and group id for the non-root containers
+	nonRootUID = int64(1000)
+
+	// sshConfigDir is where the Secret gets mounted in the sync containers
+	sshConfigDir   = "/etc/warp"
+	sshdConfigKey  = "sshd_config"
+	passwdKey      = "passwd"
+	tmpVolumeName  = "tmp"
+	nonRootHomeDir = "/tmp"
+	sshdPath       = "/usr/sbin/sshd"
+)
+
+// nonRootMode lets the fsGroup read the Secret files, the non-root user doesn't own them
+var nonRootMode = int32(0440)
+
+// sshdConfig returns the sshd configuration what accepts only the user with the key from the Secret directory.
+// The Secret volume directory is world writable, so the StrictModes must be off.
+func sshdConfig(port int, user, pidFile string) string {
+	return fmt.Sprintf(`Port %d
+HostKey %s/%s
+AuthorizedKeysFile %s/%s
+PidFile %s
+StrictModes no
+PasswordAuthentication no
+ChallengeResponseAuthentication no
+AllowUsers %s
+`, port, sshConfigDir, hostKeyKey, sshConfigDir, authorizedKeysKey, pidFile, user)
+}
+
+// passwd adds the non-root user, because sshd accepts only users what exist in the passwd file
+var passwd = fmt.Sprintf(`root:x:0:0:root:/root:/bin/sh
+%s:x:%d:%d:%s:%s:/bin/sh
+`, nonRootUser, nonRootUID, nonRootUID, nonRootUser, nonRootHomeDir)
+
+// ParseSecurityProfile validates the security profile name
+func ParseSecurityProfile(value string) (SecurityProfile, error) {
+	switch p := SecurityProfile(value); p {
+	case DefaultSecurityProfile, RestrictedSecurityProfile:
+		return p, nil
+	}
+	return "", fmt.Errorf("Invalid security profile %q, must be default or restricted", value)
+}
+
+// SSHEndpoint is the port where the Pod sshd listens and the user it accepts
+type SSHEndpoint struct {
+	Port uint16
+	User string
+}
+
+// GetSSHEndpoint returns the sshd endpoint of the warp Pod sync container
+func GetSSHEndpoint(pod *apiv1.Pod) SSHEndpoint {
+	endpoint := SSHEndpoint{Port: sshPort, User: rootUser}
+	for _, container := range pod.Spec.Containers {
+		if container.Name != "sync" {
+			continue
+		}
+		for _, port := range container.Ports {
+			if port.Name == "ssh" {
+				endpoint.Port = uint16(port.ContainerPort)
+			}
+		}
+		if c := container.SecurityContext; c != nil && c.RunAsUser != nil && *c.RunAsUser != 0 {
+			endpoint.User = nonRootUser
+		}
+	}
+	return endpoint
+}
+
+// restrictPod changes the Pod to pass the restricted Pod Security Standard. The sync containers run
+// sshd as the non-root user in unprivileged port and with read-only root filesystem.
+func restrictPod(pod *apiv1.Pod) {
+	t := true
+	uid := nonRootUID
+
+	if pod.Spec.SecurityContext == nil {
+		pod.Spec.SecurityContext = &apiv1.PodSecurityContext{}
+	}
+	security := pod.Spec.SecurityContext
+	security.RunAsNonRoot = &t
+	if security.RunAsUser == nil {
+		security.RunAsUser = &uid
+	}
+	if security.RunAsGroup == nil {
+		security.RunAsGroup = &uid
+	}
+	// The fsGroup makes the volumes writable and the Secret files readable for the non-root user
+	if security.FSGroup == nil {
+		security.FSGroup = &uid
+	}
+
+	for i := range pod.Spec.InitContainers {
+		restrictContainer(&pod.Spec.InitContainers[i])
+	}
+	for i := range pod.Spec.Containers {
+		restrictContainer(&pod.Spec.Containers[i])
+	}
+
+	for i, volume := range pod.Spec.Volumes {
+		if volume.Name == "ssh-config" && volume.Secret != nil {
+			pod.Spec.Volumes[i].Secret.DefaultMode = &nonRootMode
+		}
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, apiv1.Volume{
+		Name: tmpVolumeName,
+		VolumeSource: apiv1.VolumeSource{
+			EmptyDir: &apiv1.EmptyDirVolumeSource{},
+		},
+	})
+}
+
+func restrictContainer(container *apiv1.Container) {
+	f := false
+	if container.SecurityContext == nil {
+		container.SecurityContext = &apiv1.SecurityContext{}
+	}
+	security := container.SecurityContext
+	security.Privileged = nil
+	security.AllowPrivilegeEscalation = &f
+	security.Capabilities = &apiv1.Capabilities{
+		Drop: []apiv1.Capability{"ALL"},
+	}
+
+	if container.Name != "sync" && container.Name != "sync-init" {
+		return
+	}
+
+	t := true
+	uid := nonRootUID
+	security.RunAsUser = &uid
+	security.ReadOnlyRootFilesystem = &t
+
+	container.Ports = []apiv1.ContainerPort{
+		{
+			Name:          "ssh",
+			Protocol:      apiv1.ProtocolTCP,
+			ContainerPort: nonRootSSHPort,
+		},
+	}
+	for _, probe := range []*apiv1.Probe{container.ReadinessProbe, container.LivenessProbe} {
+		if probe != nil && probe.TCPSocket != nil {
+			probe.TCPSocket.Port = intstr.FromInt(nonRootSSHPort)
+		}
+	}
+
+	// The passwd never changes, so the subPath mount is fine
+	container.VolumeMounts = append(container.VolumeMounts,
+		apiv1.VolumeMount{Name: "ssh-config", MountPath: "/etc/passwd", SubPath: passwdKey},
+		apiv1.VolumeMount{Name: tmpVolumeName, MountPath: nonRootHomeDir},
+	)
+}
+
+// securityPatches returns the patches for the security settings what this client version doesn't know
+func securityPatches(opts PodOptions) [][]byte {
+	if opts.SecurityProfile != RestrictedSecurityProfile {
+		return nil
+	}
+	return [][]byte{
+		[]byte(`{"spec":{"securityContext":{"seccompProfile":{"type":"RuntimeDefault"}}}}`),
+	}
+}
@@ -0,0 +1,63 @@
+package kubectl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestRestrictedPodManifest(t *testing.T) {
+	pod := createPodManifest("test", PodOptions{
+		Image:           "alpine",
+		WorkDir:         "/work-dir",
+		SecurityProfile: RestrictedSecurityProfile,
+	})
+
+	require.True(t, *pod.Spec.SecurityContext.RunAsNonRoot)
+	require.Equal(t, nonRootUID, *pod.Spec.SecurityContext.FSGroup)
+
+	containers := append(pod.Spec.InitContainers, pod.Spec.Containers...)
+	require.Len(t, containers, 4)
+	for _, container := range containers {
+		require.False(t, *container.SecurityContext.AllowPrivilegeEscalation, container.Name)
+		require.Equal(t, []apiv1.Capability{"ALL"}, container.SecurityContext.Capabilities.Drop, container.Name)
+	}
+
+	sync := pod.Spec.Containers[0]
+	require.Equal(t, "sync", sync.Name)
+	require.True(t, *sync.SecurityContext.ReadOnlyRootFilesystem)
+	require.Equal(t, 2222, sync.ReadinessProbe.TCPSocket.Port.IntValue())
+	require.Equal(t, SSHEndpoint{Port: 2222, User: "warp"}, GetSSHEndpoint(pod))
+	require.Contains(t, pod.Spec.InitContainers[1].Command[2], SyncedMarker)
+}
+
+func TestDefaultPodManifestSSHEndpoint(t *testing.T) {
+	pod := createPodManifest("test", PodOptions{Image: "alpine", WorkDir: "/work-dir"})
+
+	require.Nil(t, pod.Spec.SecurityContext)
+	require.Equal(t, SSHEndpoint{Port: 22, User: "root"}, GetSSHEndpoint(pod))
+}
+
+func TestRestrictedPodSeccompProfile(t *testing.T) {
+	opts := PodOptions{Image: "alpine", WorkDir: "/work-dir", SecurityProfile: RestrictedSecurityProfile}
+
+	manifest, err := patchPodManifest(createPodManifest("test", opts), securityPatches(opts))
+	require.NoError(t, err)
+
+	var result map[string]interface{}
+	require.NoError(t, json.Unmarshal(manifest, &result))
+	securityContext := result["spec"].(map[string]interface{})["securityContext"].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{"type": "RuntimeDefault"}, securityContext["seccompProfile"])
+	require.Equal(t, true, securityContext["runAsNonRoot"])
+}
+
+func TestParseSecurityProfile(t *testing.T) {
+	profile, err := ParseSecurityProfile("restricted")
+	require.NoError(t, err)
+	require.Equal(t, RestrictedSecurityProfile, profile)
+
+	_, err = ParseSecurityProfile("privileged")
+	require.Error(t, err)
+}
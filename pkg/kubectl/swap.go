@@ -0,0 +1,132 @@
+package kubectl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SwapDeployment scales the Deployment to zero so the warp Pod receives the Service traffic instead.
+// The original replica count is stored in the Deployment annotation, so the Deployment can be restored
+// even if warp gets killed. Swapping already swapped Deployment keeps the original replica count.
+func (c *Client) SwapDeployment(namespace, name, podName string) error {
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return err
+	}
+
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if owner, ok := deployment.Annotations[SwappedPodAnnotation]; ok && owner != podName {
+		if _, err := c.GetPod(namespace, owner); err == nil {
+			return fmt.Errorf("Deployment %s is already swapped to Pod %s", name, owner)
+		}
+	}
+
+	replicas, ok := deployment.Annotations[OriginalReplicasAnnotation]
+	if !ok {
+		replicas = "1"
+		if deployment.Spec.Replicas != nil {
+			replicas = strconv.Itoa(int(*deployment.Spec.Replicas))
+		}
+	}
+
+	return c.patchDeployment(namespace, name, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{
+				SwappedLabel: "true",
+			},
+			"annotations": map[string]interface{}{
+				OriginalReplicasAnnotation: replicas,
+				SwappedPodAnnotation:       podName,
+			},
+		},
+		"spec": map[string]interface{}{
+			"replicas": 0,
+		},
+	})
+}
+
+// RestoreDeployment scales the swapped Deployment back to the original replica count and returns the name of
+// the warp Pod what replaced it. Restoring the Deployment what is not swapped does nothing, and so does
+// restoring the Deployment what is swapped to other than the given Pod. Empty Pod name restores any swap.
+func (c *Client) RestoreDeployment(namespace, name, podName string) (string, error) {
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return "", err
+	}
+
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := deployment.Annotations[OriginalReplicasAnnotation]
+	if !ok {
+		return "", nil
+	}
+	if podName != "" && deployment.Annotations[SwappedPodAnnotation] != podName {
+		return "", nil
+	}
+	replicas, err := strconv.Atoi(value)
+	if err != nil {
+		return "", fmt.Errorf("Invalid %s annotation in Deployment %s: %s", OriginalReplicasAnnotation, name, err)
+	}
+
+	return deployment.Annotations[SwappedPodAnnotation], c.patchDeployment(namespace, name, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{
+				SwappedLabel: nil,
+			},
+			"annotations": map[string]interface{}{
+				OriginalReplicasAnnotation: nil,
+				SwappedPodAnnotation:       nil,
+			},
+		},
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+		},
+	})
+}
+
+// ListSwappedDeployments returns the swapped Deployments in the namespace, or in all namespaces if the
+// namespace is empty
+func (c *Client) ListSwappedDeployments(namespace string) ([]appsv1.Deployment, error) {
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := clientset.AppsV1().Deployments(namespace).List(metav1.ListOptions{LabelSelector: SwappedLabel + "=true"})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (c *Client) patchDeployment(namespace, name string, patch map[string]interface{}) error {
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	_, err = clientset.AppsV1().Deployments(namespace).Patch(name, types.MergePatchType, data)
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("Deployment %s not found", name)
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+package kubectl
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// defaultVolumeSize is the size of the PersistentVolumeClaims if not set
+var defaultVolumeSize = resource.MustParse("10Gi")
+
+// WorkdirClaimName returns the name of the PersistentVolumeClaim for the session working directory
+func WorkdirClaimName(name string) string {
+	return name + "-workdir"
+}
+
+// syncClaimName returns the name of the PersistentVolumeClaim for the synced directory
+func syncClaimName(name string, i int) string {
+	if i == 0 {
+		return WorkdirClaimName(name)
+	}
+	return fmt.Sprintf("%s-%d", WorkdirClaimName(name), i)
+}
+
+// CacheClaimName returns the name of the PersistentVolumeClaim for the session cache directory,
+// e.g. test-cache-root-npm-9a9bd570 for /root/.npm. The hash of the path keeps the paths what differ only
+// by the invalid characters, e.g. /root/.npm and /root/npm, in separate claims.
+func CacheClaimName(name, path string) string {
+	slug := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(path), "-"), "-")
+	hash := sha256.Sum256([]byte(path))
+	return fmt.Sprintf("%s-cache-%s-%x", name, slug, hash[:4])
+}
+
+// cacheVolumeName returns the name of the Pod volume for the cache directory
+func cacheVolumeName(i int) string {
+	return fmt.Sprintf("cache-%d", i)
+}
+
+// claimNames returns the names of the PersistentVolumeClaims what the session uses
+func claimNames(name string, opts PodOptions) []string {
+	names := []string{}
+	if opts.Persistent {
+		for i := range syncDirs(opts) {
+			names = append(names, syncClaimName(name, i))
+		}
+	}
+	for _, path := range opts.Caches {
+		names = append(names, CacheClaimName(name, path))
+	}
+	return names
+}
+
+func createPVCManifest(claimName string, opts PodOptions) *apiv1.PersistentVolumeClaim {
+	meta := createObjectMeta(claimName, opts)
+	meta.Annotations = map[string]string{}
+	for key, value := range opts.Annotations {
+		meta.Annotations[key] = value
+	}
+	meta.Annotations[LastUsedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+
+	size := opts.VolumeSize
+	if size.IsZero() {
+		size = defaultVolumeSize
+	}
+
+	var storageClass *string
+	if opts.StorageClass != "" {
+		storageClass = &opts.StorageClass
+	}
+
+	return &apiv1.PersistentVolumeClaim{
+		ObjectMeta: meta,
+		Spec: apiv1.PersistentVolumeClaimSpec{
+			AccessModes:      []apiv1.PersistentVolumeAccessMode{apiv1.ReadWriteOnce},
+			StorageClassName: storageClass,
+			Resources: apiv1.ResourceRequirements{
+				Requests: apiv1.ResourceList{
+					apiv1.ResourceStorage: size,
+				},
+			},
+		},
+	}
+}
+
+// ensureVolumes creates the PersistentVolumeClaims what the session uses, or marks the existing ones used
+func (c *Client) ensureVolumes(namespace, name string, opts PodOptions) error {
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return err
+	}
+	claims := clientset.CoreV1().PersistentVolumeClaims(namespace)
+
+	for _, claimName := range claimNames(name, opts) {
+		_, err := claims.Create(createPVCManifest(claimName, opts))
+		if apierrors.IsAlreadyExists(err) {
+			err = c.annotateVolume(namespace, claimName, LastUsedAnnotation, time.Now().UTC().Format(time.RFC3339))
+		}
+		if err != nil {
+			return fmt.Errorf("Cannot create the volume %s: %s", claimName, err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) annotateVolume(namespace, name, key, value string) error {
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return err
+	}
+
+	claims := clientset.CoreV1().PersistentVolumeClaims(namespace)
+	claim, err := claims.Get(name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if claim.Annotations == nil {
+		claim.Annotations = map[string]string{}
+	}
+	claim.Annotations[key] = value
+	_, err = claims.Update(claim)
+	return err
+}
+
+// ListVolumes returns the warp PersistentVolumeClaims in the namespace, or in all namespaces if the namespace is empty
+func (c *Client) ListVolumes(namespace string) ([]apiv1.PersistentVolumeClaim, error) {
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := clientset.CoreV1().PersistentVolumeClaims(namespace).List(metav1.ListOptions{LabelSelector: WarpSelector})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// DeleteVolume deletes the PersistentVolumeClaim
+func (c *Client) DeleteVolume(namespace, name string) error {
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return err
+	}
+	return clientset.CoreV1().PersistentVolumeClaims(namespace).Delete(name, &metav1.DeleteOptions{})
+}
+
+// UsedClaims returns the names of the PersistentVolumeClaims what the Pod mounts
+func UsedClaims(pod apiv1.Pod) []string {
+	names := []string{}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			names = append(names, volume.PersistentVolumeClaim.ClaimName)
+		}
+	}
+	return names
+}
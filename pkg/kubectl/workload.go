@@ -0,0 +1,230 @@
+package kubectl
+
+import (
+	"fmt"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// workloadKinds maps the supported workload kinds and their short names to the kind
+var workloadKinds = map[string]string{
+	"deployment":   "deployment",
+	"deployments":  "deployment",
+	"deploy":       "deployment",
+	"statefulset":  "statefulset",
+	"statefulsets": "statefulset",
+	"sts":          "statefulset",
+	"daemonset":    "daemonset",
+	"daemonsets":   "daemonset",
+	"ds":           "daemonset",
+	"job":          "job",
+	"jobs":         "job",
+	"pod":          "pod",
+	"pods":         "pod",
+	"po":           "pod",
+}
+
+// ParseWorkloadReference parses the reference in format KIND/NAME, e.g. deployment/api
+func ParseWorkloadReference(reference string) (string, string, error) {
+	parts := strings.SplitN(reference, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("Invalid reference %q, must be in format KIND/NAME, e.g. deployment/api", reference)
+	}
+
+	kind, ok := workloadKinds[strings.ToLower(parts[0])]
+	if !ok {
+		return "", "", fmt.Errorf("Unsupported kind %q, must be one of deployment, statefulset, daemonset, job or pod", parts[0])
+	}
+	return kind, parts[1], nil
+}
+
+// GetPodTemplate returns the Pod template of the workload, e.g. deployment/api
+func (c *Client) GetPodTemplate(namespace, reference string) (*apiv1.PodTemplateSpec, error) {
+	kind, name, err := ParseWorkloadReference(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(c.config)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case "deployment":
+		deployment, err := clientset.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &deployment.Spec.Template, nil
+	case "statefulset":
+		statefulSet, err := clientset.AppsV1().StatefulSets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &statefulSet.Spec.Template, nil
+	case "daemonset":
+		daemonSet, err := clientset.AppsV1().DaemonSets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &daemonSet.Spec.Template, nil
+	case "job":
+		job, err := clientset.BatchV1().Jobs(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &job.Spec.Template, nil
+	}
+
+	pod, err := clientset.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &apiv1.PodTemplateSpec{ObjectMeta: pod.ObjectMeta, Spec: pod.Spec}, nil
+}
+
+// baseContainerIndex returns the index of the container in the base spec what runs the command,
+// the first container if the name is empty
+func baseContainerIndex(base *apiv1.PodSpec, containerName string) (int, error) {
+	if len(base.Containers) == 0 {
+		return 0, fmt.Errorf("The base Pod has no containers")
+	}
+	if containerName == "" {
+		return 0, nil
+	}
+	for i, container := range base.Containers {
+		if container.Name == containerName {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("Container %s not found from the base Pod", containerName)
+}
+
+// validateBase checks that the base spec has the container what runs the command, and that the base
+// volumes and containers don't use the names of the generated warp volumes and containers
+func validateBase(name string, opts PodOptions) error {
+	index, err := baseContainerIndex(opts.Base, opts.BaseContainer)
+	if err != nil {
+		return err
+	}
+
+	generatedOpts := opts
+	generatedOpts.Base = nil
+	generated := createPodManifest(name, generatedOpts).Spec
+
+	for _, volume := range opts.Base.Volumes {
+		for _, v := range generated.Volumes {
+			if volume.Name == v.Name {
+				return fmt.Errorf("The base Pod volume %s conflicts with the warp volume with the same name", volume.Name)
+			}
+		}
+	}
+	for i, container := range opts.Base.Containers {
+		for _, c := range generated.Containers {
+			if i != index && container.Name == c.Name {
+				return fmt.Errorf("The base Pod container %s conflicts with the warp container with the same name", container.Name)
+			}
+		}
+	}
+	for _, container := range opts.Base.InitContainers {
+		for _, c := range generated.InitContainers {
+			if container.Name == c.Name {
+				return fmt.Errorf("The base Pod init container %s conflicts with the warp init container with the same name", container.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// applyBase builds the Pod spec from the base spec, e.g. from a Deployment, and the generated warp Pod.
+// The base container gets replaced with the exec container what has the base container settings and
+// the warp options, and the warp sync containers and volumes are added. Probes are removed because the
+// command is not the service itself.
+func applyBase(pod *apiv1.Pod, base *apiv1.PodSpec, opts PodOptions) {
+	spec := *base.DeepCopy()
+	generated := pod.Spec
+	index, _ := baseContainerIndex(base, opts.BaseContainer)
+
+	var syncContainer, runContainer apiv1.Container
+	for _, container := range generated.Containers {
+		if container.Name == "exec" {
+			runContainer = container
+		} else {
+			syncContainer = container
+		}
+	}
+
+	exec := spec.Containers[index]
+	exec.Name = runContainer.Name
+	if opts.Image != "" {
+		exec.Image = opts.Image
+	}
+	if len(opts.Command) > 0 {
+		exec.Command = opts.Command
+		exec.Args = nil
+	}
+	exec.TTY = runContainer.TTY
+	exec.Stdin = runContainer.Stdin
+	exec.StdinOnce = runContainer.StdinOnce
+	exec.WorkingDir = runContainer.WorkingDir
+	exec.LivenessProbe = nil
+	exec.ReadinessProbe = nil
+	for _, port := range runContainer.Ports {
+		if !hasContainerPort(exec, port.ContainerPort) {
+			exec.Ports = append(exec.Ports, port)
+		}
+	}
+	exec.Env = append(exec.Env, runContainer.Env...)
+	exec.EnvFrom = append(exec.EnvFrom, runContainer.EnvFrom...)
+	if opts.Requests != nil {
+		exec.Resources.Requests = opts.Requests
+	}
+	if opts.Limits != nil {
+		exec.Resources.Limits = opts.Limits
+	}
+	exec.VolumeMounts = append(exec.VolumeMounts, runContainer.VolumeMounts...)
+
+	containers := []apiv1.Container{syncContainer, exec}
+	containers = append(containers, spec.Containers[:index]...)
+	spec.Containers = append(containers, spec.Containers[index+1:]...)
+	spec.InitContainers = append(spec.InitContainers, generated.InitContainers...)
+	spec.Volumes = append(spec.Volumes, generated.Volumes...)
+	spec.RestartPolicy = generated.RestartPolicy
+	spec.ActiveDeadlineSeconds = generated.ActiveDeadlineSeconds
+
+	if opts.ServiceAccountName != "" {
+		spec.ServiceAccountName = opts.ServiceAccountName
+	}
+	if len(opts.NodeSelector) > 0 && spec.NodeSelector == nil {
+		spec.NodeSelector = map[string]string{}
+	}
+	for key, value := range opts.NodeSelector {
+		spec.NodeSelector[key] = value
+	}
+	spec.Tolerations = append(spec.Tolerations, opts.Tolerations...)
+	if opts.Affinity != nil {
+		spec.Affinity = opts.Affinity
+	}
+	// Pods copied from the running Pod have the node already set
+	spec.NodeName = opts.NodeName
+	// The admission resolves the priority from the class name, and rejects the Pod if it's already set
+	spec.Priority = nil
+	if opts.PriorityClassName != "" {
+		spec.PriorityClassName = opts.PriorityClassName
+	}
+
+	pod.Spec = spec
+}
+
+func hasContainerPort(container apiv1.Container, port int32) bool {
+	for _, p := range container.Ports {
+		if p.ContainerPort == port {
+			return true
+		}
+	}
+	return false
+}
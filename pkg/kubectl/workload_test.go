@@ -0,0 +1,106 @@
+package kubectl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestParseWorkloadReference(t *testing.T) {
+	kind, name, err := ParseWorkloadReference("deploy/api")
+	require.NoError(t, err)
+	require.Equal(t, "deployment", kind)
+	require.Equal(t, "api", name)
+
+	kind, _, err = ParseWorkloadReference("StatefulSet/db")
+	require.NoError(t, err)
+	require.Equal(t, "statefulset", kind)
+
+	_, _, err = ParseWorkloadReference("service/api")
+	require.Error(t, err)
+	_, _, err = ParseWorkloadReference("api")
+	require.Error(t, err)
+}
+
+func TestCreatePodManifestFromBase(t *testing.T) {
+	priority := int32(1000)
+	base := &apiv1.PodSpec{
+		ServiceAccountName: "api",
+		NodeName:           "worker-1",
+		PriorityClassName:  "high",
+		Priority:           &priority,
+		Containers: []apiv1.Container{
+			{Name: "proxy", Image: "cloudsql-proxy"},
+			{
+				Name:           "api",
+				Image:          "company/api",
+				Command:        []string{"/api"},
+				Args:           []string{"--port", "8080"},
+				Env:            []apiv1.EnvVar{{Name: "DB_HOST", Value: "localhost"}},
+				ReadinessProbe: &apiv1.Probe{},
+				VolumeMounts:   []apiv1.VolumeMount{{Name: "config", MountPath: "/config"}},
+			},
+		},
+		Volumes: []apiv1.Volume{{Name: "config"}},
+	}
+
+	pod := createPodManifest("test", PodOptions{
+		Command:       []string{"go", "run", "."},
+		WorkDir:       "/work-dir",
+		Env:           []apiv1.EnvVar{{Name: "DEBUG", Value: "true"}},
+		Base:          base,
+		BaseContainer: "api",
+	})
+
+	require.Equal(t, "api", pod.Spec.ServiceAccountName)
+	require.Empty(t, pod.Spec.NodeName)
+	require.Equal(t, "high", pod.Spec.PriorityClassName)
+	require.Nil(t, pod.Spec.Priority)
+	require.Equal(t, apiv1.RestartPolicyNever, pod.Spec.RestartPolicy)
+	require.Len(t, pod.Spec.Containers, 3)
+	require.Equal(t, "sync", pod.Spec.Containers[0].Name)
+	require.Equal(t, "proxy", pod.Spec.Containers[2].Name)
+	require.Len(t, pod.Spec.Volumes, 3)
+	require.Equal(t, "sync-check", pod.Spec.InitContainers[0].Name)
+	require.Equal(t, "sync-init", pod.Spec.InitContainers[1].Name)
+
+	exec := pod.Spec.Containers[1]
+	require.Equal(t, "exec", exec.Name)
+	require.Equal(t, "company/api", exec.Image)
+	require.Equal(t, "company/api", pod.Annotations[ImageAnnotation])
+	require.Equal(t, []string{"go", "run", "."}, exec.Command)
+	require.Empty(t, exec.Args)
+	require.Equal(t, "/work-dir", exec.WorkingDir)
+	require.Nil(t, exec.ReadinessProbe)
+	require.Equal(t, []apiv1.EnvVar{{Name: "DB_HOST", Value: "localhost"}, {Name: "DEBUG", Value: "true"}}, exec.Env)
+	require.Len(t, exec.VolumeMounts, 2)
+
+	// The base must not be modified
+	require.Equal(t, "api", base.Containers[1].Name)
+
+	_, err := baseContainerIndex(base, "missing")
+	require.Error(t, err)
+}
+
+func TestValidateBase(t *testing.T) {
+	opts := PodOptions{
+		WorkDir: "/work-dir",
+		Base: &apiv1.PodSpec{
+			Containers: []apiv1.Container{{Name: "api", Image: "company/api"}},
+			Volumes:    []apiv1.Volume{{Name: "config"}},
+		},
+	}
+	require.NoError(t, validateBase("test", opts))
+
+	opts.Base.Volumes = append(opts.Base.Volumes, apiv1.Volume{Name: "workdir"})
+	require.Error(t, validateBase("test", opts))
+
+	opts.Base.Volumes = nil
+	opts.Base.Containers = append(opts.Base.Containers, apiv1.Container{Name: "sync"})
+	require.Error(t, validateBase("test", opts))
+
+	opts.Base.Containers = opts.Base.Containers[:1]
+	opts.Base.InitContainers = []apiv1.Container{{Name: "sync-init"}}
+	require.Error(t, validateBase("test", opts))
+}
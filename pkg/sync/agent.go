@@ -0,0 +1,74 @@
+package sync
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/ernoaapa/kubectl-warp/pkg/cert"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Agent is in-process ssh-agent what serves the private key to the ssh client through unix socket,
+// so the private key never gets written to the disk
+type Agent struct {
+	dir      string
+	listener net.Listener
+}
+
+// NewAgent starts serving the private key in new unix socket in private temporary directory
+func NewAgent(privateKey []byte) (*Agent, error) {
+	key, err := cert.ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		return nil, err
+	}
+
+	// TempDir creates the directory with 0700 permissions so only the current user can use the socket
+	dir, err := ioutil.TempDir("", "warp-agent")
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", filepath.Join(dir, "agent.sock"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	a := &Agent{
+		dir:      dir,
+		listener: listener,
+	}
+	go a.serve(keyring)
+	return a, nil
+}
+
+// Socket returns the path to the agent socket, to be used as SSH_AUTH_SOCK
+func (a *Agent) Socket() string {
+	return a.listener.Addr().String()
+}
+
+// Close stops serving the key and removes the socket
+func (a *Agent) Close() error {
+	a.listener.Close()
+	return os.RemoveAll(a.dir)
+}
+
+func (a *Agent) serve(keyring agent.Agent) {
+	for {
+		conn, err := a.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			agent.ServeAgent(keyring, conn)
+		}()
+	}
+}
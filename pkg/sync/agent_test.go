@@ -0,0 +1,40 @@
+package sync
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ernoaapa/kubectl-warp/pkg/cert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestAgentServesPrivateKey(t *testing.T) {
+	privateKey, publicKey, err := cert.Create(cert.Ed25519)
+	require.NoError(t, err)
+
+	a, err := NewAgent(privateKey)
+	require.NoError(t, err)
+	defer a.Close()
+
+	conn, err := net.Dial("unix", a.Socket())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	keys, err := agent.NewClient(conn).List()
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.Equal(t, string(publicKey), keys[0].String()+"\n")
+}
+
+func TestAgentCloseRemovesSocket(t *testing.T) {
+	privateKey, _, err := cert.CreateHostKey()
+	require.NoError(t, err)
+
+	a, err := NewAgent(privateKey)
+	require.NoError(t, err)
+	require.NoError(t, a.Close())
+
+	_, err = net.Dial("unix", a.Socket())
+	require.Error(t, err)
+}
@@ -0,0 +1,287 @@
+package sync
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Filter decides which files get synchronized, using similar include/exclude rules than rsync.
+// The first matching rule decides is the path included or excluded and paths what doesn't
+// match any of the rules are included.
+type Filter struct {
+	includes []string
+	excludes []string
+	// rules are the compiled includes and excludes, they take precedence over the ignore file rules
+	rules []rule
+	// ignores are the rules from the ignore files, ordered so that the first match wins
+	ignores []rule
+}
+
+type rule struct {
+	pattern *regexp.Regexp
+	include bool
+	dirOnly bool
+	// rsync is the rule in rsync pattern format
+	rsync []string
+}
+
+// NewFilter creates new Filter from rsync style --include and --exclude patterns.
+// Includes take precedence over the excludes.
+func NewFilter(includes, excludes []string) *Filter {
+	f := &Filter{
+		includes: includes,
+		excludes: excludes,
+	}
+	for _, p := range includes {
+		f.rules = append(f.rules, newRsyncRule(p, true))
+	}
+	for _, p := range excludes {
+		f.rules = append(f.rules, newRsyncRule(p, false))
+	}
+	return f
+}
+
+// LoadFilter creates new Filter from rsync style --include and --exclude patterns and
+// the gitignore style ignore files (e.g. .gitignore) found under the root directory.
+// Like in git, the nested ignore files take precedence over the parent directory ignore files.
+func LoadFilter(root string, includes, excludes, ignoreFiles []string) (*Filter, error) {
+	f := NewFilter(includes, excludes)
+	if len(ignoreFiles) == 0 {
+		return f, nil
+	}
+
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			// File might have been removed while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			rel = ""
+		} else if f.Excluded(rel, true) {
+			return filepath.SkipDir
+		}
+
+		for _, name := range ignoreFiles {
+			if err := f.addIgnoreFile(filepath.Join(p, name), filepath.ToSlash(rel)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return f, err
+}
+
+// Excluded returns true if the path (relative to the sync root) should not be synchronized.
+// The path is excluded also if any of its parent directories is excluded.
+func (f *Filter) Excluded(p string, isDir bool) bool {
+	p = filepath.ToSlash(filepath.Clean(p))
+
+	parts := strings.Split(p, "/")
+	for i := 1; i < len(parts); i++ {
+		if f.excluded(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return f.excluded(p, isDir)
+}
+
+func (f *Filter) excluded(p string, isDir bool) bool {
+	for _, rules := range [][]rule{f.rules, f.ignores} {
+		for _, r := range rules {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if r.pattern.MatchString(p) {
+				return !r.include
+			}
+		}
+	}
+	return false
+}
+
+// addIgnoreFile reads the gitignore style file if it exists and adds the rules to the filter.
+// The base is the directory of the ignore file, relative to the sync root.
+func (f *Filter) addIgnoreFile(filename, base string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	rules, err := parseIgnoreFile(file, base)
+	if err != nil {
+		return err
+	}
+
+	// In ignore files the last matching pattern wins, and nested files win the parent files,
+	// so reverse the rules and put them before the previously loaded ones
+	reversed := make([]rule, 0, len(rules)+len(f.ignores))
+	for i := len(rules) - 1; i >= 0; i-- {
+		reversed = append(reversed, rules[i])
+	}
+	f.ignores = append(reversed, f.ignores...)
+	return nil
+}
+
+// parseIgnoreFile parses gitignore style patterns.
+// See: https://git-scm.com/docs/gitignore#_pattern_format
+func parseIgnoreFile(r io.Reader, base string) ([]rule, error) {
+	rules := []rule{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+			line = strings.TrimSuffix(line, " ")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		include := false
+		if strings.HasPrefix(line, "!") {
+			include = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+
+		dirOnly := strings.HasSuffix(line, "/")
+		line = strings.TrimSuffix(line, "/")
+		if line == "" {
+			continue
+		}
+
+		// Pattern with slash in the beginning or middle is relative to the ignore file directory
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+
+		r := newRule(base, line, anchored, include, dirOnly)
+		r.rsync = ignoreToRsync(base, line, anchored, dirOnly)
+		rules = append(rules, r)
+	}
+	return rules, scanner.Err()
+}
+
+// ignoreToRsync converts the ignore file pattern to rsync patterns
+func ignoreToRsync(base, pattern string, anchored, dirOnly bool) []string {
+	suffix := ""
+	if dirOnly {
+		suffix = "/"
+	}
+
+	if anchored {
+		return []string{"/" + path.Join(base, pattern) + suffix}
+	}
+	if base == "" {
+		return []string{pattern + suffix}
+	}
+	return []string{
+		"/" + path.Join(base, pattern) + suffix,
+		"/" + path.Join(base, "**", pattern) + suffix,
+	}
+}
+
+// newRsyncRule creates rule from rsync pattern. Pattern with leading slash is anchored to the sync root
+// and pattern ending to "/***" matches the directory and everything inside it.
+// See: https://download.samba.org/pub/rsync/rsync.html#INCLUDE/EXCLUDE_PATTERN_RULES
+func newRsyncRule(pattern string, include bool) rule {
+	anchored := strings.HasPrefix(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	return newRule("", pattern, anchored, include, dirOnly)
+}
+
+func newRule(base, pattern string, anchored, include, dirOnly bool) rule {
+	contents := false
+	if strings.HasSuffix(pattern, "/***") {
+		pattern = strings.TrimSuffix(pattern, "/***")
+		contents = true
+	}
+
+	expr := "^"
+	if base != "" {
+		expr += regexp.QuoteMeta(base) + "/"
+	}
+	if !anchored {
+		expr += "(.*/)?"
+	}
+	expr += globToRegexp(pattern)
+	if contents {
+		expr += "(/.*)?"
+	}
+	expr += "$"
+
+	return rule{
+		pattern: regexp.MustCompile(expr),
+		include: include,
+		dirOnly: dirOnly && !contents,
+	}
+}
+
+// globToRegexp converts shell glob pattern to regular expression where "*" matches anything except slash
+// and "**" matches anything including slashes
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				// "**/" matches also zero directories
+				if i+2 < len(glob) && glob[i+2] == '/' {
+					b.WriteString("(.*/)?")
+					i += 2
+				} else {
+					b.WriteString(".*")
+					i++
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package sync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterExcluded(t *testing.T) {
+	f := NewFilter([]string{"node_modules/", "node_modules/keep/***"}, []string{"node_modules/***", "*.log", "/build", "tmp/"})
+
+	require.False(t, f.Excluded("node_modules", true))
+	require.True(t, f.Excluded("node_modules/foo", true))
+	require.True(t, f.Excluded("node_modules/foo/index.js", false))
+	require.False(t, f.Excluded("node_modules/keep/index.js", false))
+	require.True(t, f.Excluded("debug.log", false))
+	require.True(t, f.Excluded("sub/dir/debug.log", false))
+	require.True(t, f.Excluded("build", true))
+	require.False(t, f.Excluded("sub/build", true))
+	require.True(t, f.Excluded("sub/tmp", true))
+	require.False(t, f.Excluded("sub/tmp", false))
+	require.False(t, f.Excluded("index.js", false))
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	require.Equal(t, `[^/]*\.go`, globToRegexp("*.go"))
+	require.Equal(t, `(.*/)?foo`, globToRegexp("**/foo"))
+	require.Equal(t, `foo/.*`, globToRegexp("foo/**"))
+	require.Equal(t, `file[^0-9]`, globToRegexp("file[!0-9]"))
+}
+
+func TestParseIgnoreFile(t *testing.T) {
+	rules, err := parseIgnoreFile(strings.NewReader(`
+# comment
+*.log
+!keep.log
+/build/
+docs/*.html
+\#hash
+`), "sub")
+	require.NoError(t, err)
+	require.Len(t, rules, 5)
+
+	require.Equal(t, []string{"/sub/*.log", "/sub/**/*.log"}, rules[0].rsync)
+	require.True(t, rules[1].include)
+	require.Equal(t, []string{"/sub/build/"}, rules[2].rsync)
+	require.True(t, rules[2].dirOnly)
+	require.Equal(t, []string{"/sub/docs/*.html"}, rules[3].rsync)
+	require.Equal(t, []string{"/sub/#hash", "/sub/**/#hash"}, rules[4].rsync)
+}
+
+func TestLoadFilterNestedIgnoreFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "warp-filter")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "sub", "node_modules"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.log\nnode_modules/\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "sub", ".gitignore"), []byte("!important.log\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "sub", ".warpignore"), []byte("generated/\n"), 0644))
+
+	f, err := LoadFilter(root, []string{}, []string{".git/"}, []string{".gitignore", ".warpignore"})
+	require.NoError(t, err)
+
+	require.True(t, f.Excluded(".git/config", false))
+	require.True(t, f.Excluded("debug.log", false))
+	require.True(t, f.Excluded("sub/debug.log", false))
+	require.False(t, f.Excluded("sub/important.log", false))
+	require.True(t, f.Excluded("important.log", false))
+	require.True(t, f.Excluded("sub/node_modules/dep/index.js", false))
+	require.True(t, f.Excluded("sub/generated", true))
+	require.False(t, f.Excluded("generated", true))
+	require.False(t, f.Excluded("sub/index.js", false))
+
+	require.Equal(t, []string{
+		"--exclude=.git/",
+		"--filter=- /sub/generated/",
+		"--filter=- /sub/**/generated/",
+		"--filter=+ /sub/important.log",
+		"--filter=+ /sub/**/important.log",
+		"--filter=- node_modules/",
+		"--filter=- *.log",
+	}, filterArgs(f))
+}
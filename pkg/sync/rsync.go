@@ -1,45 +1,170 @@
 package sync
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
+	"strings"
+
+	"github.com/ernoaapa/kubectl-warp/pkg/utils"
+	"golang.org/x/crypto/ssh"
 )
 
+// Rsync is Syncer what executes local rsync binary to synchronise the files over the SSH
 type Rsync struct {
-	sshPort        uint16
-	args           []string
-	privateKeyFile string
-	stdout         io.Writer
-	stderr         io.Writer
+	sshPort          uint16
+	user             string
+	args             []string
+	agentSocket      string
+	knownHostsFile   string
+	hostKeyAlgorithm string
+	stdout           io.Writer
+	stderr           io.Writer
 }
 
-// NewRsync create new instance of rsync executor
-func NewRsync(sshPort uint16, args []string, privateKeyFile string, stdout, stderr io.Writer) *Rsync {
+// NewRsync create new instance of rsync executor what connects as the user. The ssh gets the client key
+// from the agent socket and accepts only the host key. Close must be called when done.
+func NewRsync(sshPort uint16, user string, args []string, agentSocket string, hostKey []byte, stdout, stderr io.Writer) (*Rsync, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey(hostKey)
+	if err != nil {
+		return nil, err
+	}
+
+	knownHostsFile, err := utils.CreateTempFile([]byte(knownHostsLine(key)))
+	if err != nil {
+		return nil, err
+	}
+
 	return &Rsync{
-		sshPort:        sshPort,
-		stdout:         stdout,
-		stderr:         stderr,
-		args:           args,
-		privateKeyFile: privateKeyFile,
+		sshPort:          sshPort,
+		user:             user,
+		stdout:           stdout,
+		stderr:           stderr,
+		args:             args,
+		agentSocket:      agentSocket,
+		knownHostsFile:   knownHostsFile,
+		hostKeyAlgorithm: key.Type(),
+	}, nil
+}
+
+// Close removes the known_hosts file
+func (s *Rsync) Close() error {
+	return os.Remove(s.knownHostsFile)
+}
+
+// Sync executes underying rsync to synchronize the source directory files to target host
+func (s *Rsync) Sync(source, destination string, filter *Filter) error {
+	destination = fmt.Sprintf("%s@localhost:%s", s.user, destination)
+
+	args := append(s.args, "--rsh", s.rsh())
+	args = append(args, filterArgs(filter)...)
+
+	// The trailing slash syncs the directory content instead of the directory itself
+	return s.run(append(args, strings.TrimSuffix(source, "/")+"/", destination)...)
+}
+
+// Pull executes underlying rsync to synchronize the paths from the target host to the destination directory.
+// The paths what don't exist in the target host are skipped.
+func (s *Rsync) Pull(source, destination string, paths []string, policy ConflictPolicy) error {
+	// The --ignore-missing-args needs rsync 3.1, so check the paths instead to support the older versions
+	paths, err := s.existingPaths(source, paths)
+	if err != nil {
+		return err
 	}
+
+	args := append(s.args, "--rsh", s.rsh(), "--relative")
+	switch policy {
+	case LocalWins:
+		args = append(args, "--ignore-existing")
+	case NewestWins:
+		args = append(args, "--update")
+	}
+
+	for _, p := range paths {
+		// The "/./" marks the point where the --relative path starts
+		source := fmt.Sprintf("%s@localhost:%s/./%s", s.user, strings.TrimSuffix(source, "/"), p)
+		if err := s.run(append(args, source, destination)...); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// Sync executes underying rsync to synchronize fiels to target host
-func (s *Rsync) Sync(destination string, includes, excludes []string) error {
-	args := s.args
-	rsh := fmt.Sprintf("/usr/bin/ssh -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null -o LogLevel=ERROR -p %d -i %s", s.sshPort, s.privateKeyFile)
-	args = append(args, "--rsh", rsh)
+// existingPaths returns the paths what exist in the source directory of the target host
+func (s *Rsync) existingPaths(source string, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return paths, nil
+	}
 
-	args = append(args, prefix("--include=", includes)...)
-	args = append(args, prefix("--exclude=", excludes)...)
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = shellQuote(p)
+	}
+	script := fmt.Sprintf(`cd %s || exit 1; for p in %s; do [ -e "$p" ] && echo "$p"; done; exit 0`, shellQuote(source), strings.Join(quoted, " "))
+
+	stdout := &bytes.Buffer{}
+	cmd := exec.Command("/usr/bin/ssh", append(s.sshArgs(), s.user+"@localhost", script)...)
+	cmd.Env = append(os.Environ(), "SSH_AUTH_SOCK="+s.agentSocket)
+	cmd.Stdout = stdout
+	cmd.Stderr = s.stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	existing := []string{}
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if line != "" {
+			existing = append(existing, line)
+		}
+	}
+	return existing, nil
+}
+
+func (s *Rsync) rsh() string {
+	return "/usr/bin/ssh " + strings.Join(s.sshArgs(), " ")
+}
 
-	cmd := exec.Command("rsync", append(args, ".", destination)...)
+// sshArgs returns the ssh arguments what accept only the pinned host key
+func (s *Rsync) sshArgs() []string {
+	return []string{
+		"-o", "StrictHostKeyChecking=yes",
+		"-o", "UserKnownHostsFile=" + s.knownHostsFile,
+		"-o", "HostKeyAlias=" + hostKeyAlias,
+		"-o", "HostKeyAlgorithms=" + s.hostKeyAlgorithm,
+		"-o", "LogLevel=ERROR",
+		"-p", fmt.Sprint(s.sshPort),
+	}
+}
+
+func (s *Rsync) run(args ...string) error {
+	cmd := exec.Command("rsync", args...)
+	cmd.Env = append(os.Environ(), "SSH_AUTH_SOCK="+s.agentSocket)
 	cmd.Stdout = s.stdout
 	cmd.Stderr = s.stderr
 	return cmd.Run()
 }
 
+// knownHostsLine returns the known_hosts file line what pins the host key to the hostKeyAlias
+func knownHostsLine(key ssh.PublicKey) string {
+	return hostKeyAlias + " " + string(ssh.MarshalAuthorizedKey(key))
+}
+
+// filterArgs returns rsync arguments for the filter rules in the same precedence order
+func filterArgs(f *Filter) []string {
+	args := prefix("--include=", f.includes)
+	args = append(args, prefix("--exclude=", f.excludes)...)
+	for _, r := range f.ignores {
+		p := "--filter=- "
+		if r.include {
+			p = "--filter=+ "
+		}
+		args = append(args, prefix(p, r.rsync)...)
+	}
+	return args
+}
+
 func prefix(p string, s []string) []string {
 	r := []string{}
 	for _, e := range s {
@@ -1,11 +1,30 @@
 package sync
 
 import (
+	"io/ioutil"
 	"testing"
 
+	"github.com/ernoaapa/kubectl-warp/pkg/cert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestPrefix(t *testing.T) {
 	require.Equal(t, []string{"pre-foo", "pre-bar"}, prefix("pre-", []string{"foo", "bar"}))
 }
+
+func TestRsyncPinsHostKey(t *testing.T) {
+	_, hostKey, err := cert.CreateHostKey()
+	require.NoError(t, err)
+
+	s, err := NewRsync(2222, "root", []string{}, "/tmp/agent.sock", hostKey, nil, nil)
+	require.NoError(t, err)
+	defer s.Close()
+
+	knownHosts, err := ioutil.ReadFile(s.knownHostsFile)
+	require.NoError(t, err)
+	require.Equal(t, "warp "+string(hostKey), string(knownHosts))
+
+	require.Contains(t, s.rsh(), "-o StrictHostKeyChecking=yes")
+	require.Contains(t, s.rsh(), "-o HostKeyAlgorithms=ecdsa-sha2-nistp256")
+	require.NotContains(t, s.rsh(), " -i ")
+}
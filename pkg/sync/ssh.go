@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ernoaapa/kubectl-warp/pkg/cert"
+	"golang.org/x/crypto/ssh"
+)
+
+// hostKeyAlias is the host name what the Pod host key is pinned with in the known_hosts file,
+// because the forwarded port changes in every session
+const hostKeyAlias = "warp"
+
+// Executor executes shell script in the remote host
+type Executor interface {
+	Execute(script string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+// SSHExecutor executes shell scripts in the remote host over the SSH connection
+type SSHExecutor struct {
+	addr   string
+	config *ssh.ClientConfig
+}
+
+// NewSSHExecutor creates new executor what connects as the user to the localhost sshPort with the private
+// key and accepts only the host key
+func NewSSHExecutor(sshPort uint16, user string, privateKey, hostKey []byte) (*SSHExecutor, error) {
+	rawKey, err := cert.ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(rawKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey(hostKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SSHExecutor{
+		addr: fmt.Sprintf("localhost:%d", sshPort),
+		config: &ssh.ClientConfig{
+			User:            user,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: ssh.FixedHostKey(key),
+			// The sshd may have other host keys too, so ask for the one we know
+			HostKeyAlgorithms: []string{key.Type()},
+			Timeout:           10 * time.Second,
+		},
+	}, nil
+}
+
+// Execute opens new connection and runs the script in it
+func (e *SSHExecutor) Execute(script string, stdin io.Reader, stdout, stderr io.Writer) error {
+	client, err := ssh.Dial("tcp", e.addr, e.config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+	return session.Run(script)
+}
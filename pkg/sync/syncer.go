@@ -0,0 +1,36 @@
+package sync
+
+import "fmt"
+
+// Syncer synchronises the local files to the remote directory
+type Syncer interface {
+	// Sync synchronises files from the local source directory to the destination directory in the remote host.
+	// Files what the filter excludes are not synchronised.
+	Sync(source, destination string, filter *Filter) error
+
+	// Pull synchronises the paths (relative to the source directory in the remote host) back to the
+	// local destination directory. The policy decides what to do when the file exist in both.
+	// Paths what doesn't exist in the remote host are skipped.
+	Pull(source, destination string, paths []string, policy ConflictPolicy) error
+}
+
+// ConflictPolicy decides which file to keep when pulling file what exist both locally and remotely
+type ConflictPolicy string
+
+const (
+	// LocalWins keeps the local file and pulls only new files
+	LocalWins ConflictPolicy = "local"
+	// RemoteWins always overwrites the local file with the remote file
+	RemoteWins ConflictPolicy = "remote"
+	// NewestWins overwrites the local file if the remote file is newer
+	NewestWins ConflictPolicy = "newest"
+)
+
+// ParseConflictPolicy returns the ConflictPolicy with the name or error if it's not valid
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(name); p {
+	case LocalWins, RemoteWins, NewestWins:
+		return p, nil
+	}
+	return "", fmt.Errorf("Invalid conflict policy %q, must be one of %s, %s or %s", name, LocalWins, RemoteWins, NewestWins)
+}
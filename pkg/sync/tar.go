@@ -0,0 +1,339 @@
+package sync
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	gosync "sync"
+	"time"
+)
+
+// Tar is Syncer what streams the changed files as tar archive to the remote host.
+// It doesn't need any local binaries, only the tar in the remote host.
+type Tar struct {
+	executor Executor
+	stdout   io.Writer
+	stderr   io.Writer
+
+	mu gosync.Mutex
+	// synced is the state of the synced files by the local directory
+	synced map[string]map[string]fileState
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+	mode    os.FileMode
+}
+
+// NewTar creates new instance of tar syncer what executes the remote commands with the executor
+func NewTar(executor Executor, stdout, stderr io.Writer) *Tar {
+	return &Tar{
+		executor: executor,
+		stdout:   stdout,
+		stderr:   stderr,
+		synced:   map[string]map[string]fileState{},
+	}
+}
+
+// Sync sends the files what have changed since the last sync from the source directory to the destination
+// directory. Like rsync without --delete, files what are removed locally are not removed from the remote.
+func (s *Tar) Sync(source, destination string, filter *Filter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	source = filepath.Clean(source)
+	previous, synced := s.synced[source]
+	files, state, err := changedFiles(source, filter, previous)
+	if err != nil {
+		return err
+	}
+
+	// First sync must always be executed, e.g. the sync-init container waits for it
+	if len(files) == 0 && synced {
+		return nil
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(writeTar(w, source, files))
+	}()
+
+	script := fmt.Sprintf("mkdir -p %s && tar -xf - -C %s", shellQuote(destination), shellQuote(destination))
+	if err := s.executor.Execute(script, r, s.stdout, s.stderr); err != nil {
+		r.CloseWithError(err)
+		return err
+	}
+
+	s.synced[source] = state
+	return nil
+}
+
+// Pull reads the paths from the remote source directory as tar archive and extracts them to the local
+// destination directory
+func (s *Tar) Pull(source, destination string, paths []string, policy ConflictPolicy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = shellQuote(p)
+	}
+	script := fmt.Sprintf(`cd %s && set -- && for p in %s; do [ -e "$p" ] && set -- "$@" "$p"; done; [ $# -eq 0 ] || tar -cf - "$@"`,
+		shellQuote(source), strings.Join(quoted, " "))
+
+	r, w := io.Pipe()
+	result := make(chan error, 1)
+	go func() {
+		err := s.extractTar(r, filepath.Clean(destination), policy)
+		// Drain the rest so the remote command doesn't block
+		io.Copy(ioutil.Discard, r)
+		result <- err
+	}()
+
+	err := s.executor.Execute(script, nil, w, s.stderr)
+	w.CloseWithError(err)
+	if extractErr := <-result; err == nil {
+		err = extractErr
+	}
+	return err
+}
+
+// extractTar extracts the tar archive to the destination directory with the conflict policy
+func (s *Tar) extractTar(r io.Reader, destination string, policy ConflictPolicy) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if escapes(name) {
+			return fmt.Errorf("Invalid path %s in the archive", hdr.Name)
+		}
+		if hdr.Typeflag == tar.TypeSymlink {
+			link := filepath.FromSlash(hdr.Linkname)
+			if filepath.IsAbs(link) || escapes(filepath.Join(filepath.Dir(name), link)) {
+				return fmt.Errorf("Invalid link %s -> %s in the archive", hdr.Name, hdr.Linkname)
+			}
+		}
+		if err := checkNoSymlinks(destination, filepath.Dir(name)); err != nil {
+			return err
+		}
+		path := filepath.Join(destination, name)
+
+		if hdr.Typeflag != tar.TypeDir {
+			if local, err := os.Lstat(path); err == nil {
+				if policy == LocalWins || (policy == NewestWins && !hdr.ModTime.After(local.ModTime())) {
+					continue
+				}
+			}
+		}
+
+		if err := extractTarEntry(tr, hdr, path); err != nil {
+			return err
+		}
+
+		// Store the state so the pulled files don't get synced back
+		if info, err := os.Lstat(path); err == nil && s.synced[destination] != nil {
+			s.synced[destination][name] = fileState{
+				size:    info.Size(),
+				modTime: info.ModTime(),
+				mode:    info.Mode(),
+			}
+		}
+	}
+}
+
+// escapes returns true if the cleaned relative path points outside of its root directory
+func escapes(name string) bool {
+	return filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator))
+}
+
+// checkNoSymlinks returns error if any component of the relative directory under the root is a
+// symlink, so the archive cannot write outside of the root through a link
+func checkNoSymlinks(root, dir string) error {
+	if dir == "." {
+		return nil
+	}
+	path := root
+	for _, component := range strings.Split(dir, string(filepath.Separator)) {
+		path = filepath.Join(path, component)
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("Invalid path %s in the archive, it goes through symlink %s", dir, path)
+		}
+	}
+	return nil
+}
+
+func extractTarEntry(tr *tar.Reader, hdr *tar.Header, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(path, os.FileMode(hdr.Mode).Perm())
+
+	case tar.TypeSymlink:
+		os.Remove(path)
+		return os.Symlink(hdr.Linkname, path)
+
+	case tar.TypeReg, tar.TypeRegA:
+		// Replace the symlink instead of writing to its target
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode).Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		return os.Chtimes(path, hdr.ModTime, hdr.ModTime)
+	}
+
+	// Skip devices, hard links, etc.
+	return nil
+}
+
+// changedFiles walks the root directory and returns files what are new or changed since the previous sync,
+// and the state of all files what should be stored if the sync succeeds. The paths are relative to the root.
+func changedFiles(root string, filter *Filter, previous map[string]fileState) ([]string, map[string]fileState, error) {
+	changed := []string{}
+	state := map[string]fileState{}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// File might have been removed while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		path, err = filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if filter.Excluded(path, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !info.Mode().IsRegular() && !info.IsDir() && info.Mode()&os.ModeSymlink == 0 {
+			// Skip devices, sockets, etc.
+			return nil
+		}
+
+		current := fileState{
+			size:    info.Size(),
+			modTime: info.ModTime(),
+			mode:    info.Mode(),
+		}
+		state[path] = current
+
+		last, ok := previous[path]
+		if info.IsDir() {
+			// Directories get listed only once, modification time changes every time when files change
+			if !ok {
+				changed = append(changed, path)
+			}
+		} else if !ok || last != current {
+			changed = append(changed, path)
+		}
+		return nil
+	})
+
+	return changed, state, err
+}
+
+// writeTar writes the files in the root directory in tar format to the writer
+func writeTar(w io.Writer, root string, files []string) error {
+	tw := tar.NewWriter(w)
+	for _, name := range files {
+		if err := writeTarEntry(tw, root, name); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func writeTarEntry(tw *tar.Writer, root, name string) error {
+	path := filepath.Join(root, name)
+	info, err := os.Lstat(path)
+	if err != nil {
+		// File have been removed after we listed it
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	}
+
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = filepath.ToSlash(name)
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+	// Like rsync without --owner and --group, the files are owned by the remote user
+	hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.CopyN(tw, f, hdr.Size)
+	return err
+}
+
+// shellQuote quotes the string so it can be safely passed to the remote shell
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,176 @@
+package sync
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeExecutor struct {
+	scripts []string
+	files   []string
+	output  []byte
+}
+
+func (e *fakeExecutor) Execute(script string, stdin io.Reader, stdout, stderr io.Writer) error {
+	e.scripts = append(e.scripts, script)
+	e.files = []string{}
+
+	if stdin == nil {
+		_, err := stdout.Write(e.output)
+		return err
+	}
+
+	tr := tar.NewReader(stdin)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e.files = append(e.files, hdr.Name)
+	}
+}
+
+func TestTarSyncSendsOnlyChangedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warp-tar")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	require.NoError(t, os.MkdirAll(filepath.Join("src", "node_modules"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join("src", "index.js"), []byte("foo"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join("src", "node_modules", "dep.js"), []byte("bar"), 0644))
+
+	executor := &fakeExecutor{}
+	s := NewTar(executor, ioutil.Discard, ioutil.Discard)
+
+	require.NoError(t, s.Sync(".", "/work-dir", NewFilter([]string{}, []string{"node_modules/***"})))
+	require.Equal(t, []string{"mkdir -p '/work-dir' && tar -xf - -C '/work-dir'"}, executor.scripts)
+	require.Equal(t, []string{"src/", "src/index.js"}, executor.files)
+
+	require.NoError(t, s.Sync(".", "/work-dir", NewFilter([]string{}, []string{"node_modules/***"})))
+	require.Len(t, executor.scripts, 1, "Should not execute when nothing changed")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join("src", "new.js"), []byte("baz"), 0644))
+	require.NoError(t, s.Sync(".", "/work-dir", NewFilter([]string{}, []string{"node_modules/***"})))
+	require.Equal(t, []string{"src/new.js"}, executor.files)
+}
+
+func TestTarPullWithNewestWinsPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warp-tar")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	now := time.Now()
+	require.NoError(t, os.MkdirAll("gen", 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join("gen", "newer-locally.go"), []byte("local"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join("gen", "older-locally.go"), []byte("local"), 0644))
+	require.NoError(t, os.Chtimes(filepath.Join("gen", "older-locally.go"), now.Add(-time.Hour), now.Add(-time.Hour)))
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for _, name := range []string{"gen/newer-locally.go", "gen/older-locally.go", "gen/new.go"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 6, ModTime: now.Add(-time.Minute), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte("remote"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	executor := &fakeExecutor{output: archive.Bytes()}
+	s := NewTar(executor, ioutil.Discard, ioutil.Discard)
+	require.NoError(t, s.Pull("/work-dir", ".", []string{"gen"}, NewestWins))
+
+	requireContent(t, "local", filepath.Join("gen", "newer-locally.go"))
+	requireContent(t, "remote", filepath.Join("gen", "older-locally.go"))
+	requireContent(t, "remote", filepath.Join("gen", "new.go"))
+}
+
+func TestTarSyncFromSubdirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warp-tar")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "services", "api"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "services", "api", "main.go"), []byte("package main"), 0644))
+
+	executor := &fakeExecutor{}
+	s := NewTar(executor, ioutil.Discard, ioutil.Discard)
+
+	require.NoError(t, s.Sync(filepath.Join(dir, "services", "api"), "/app", NewFilter([]string{}, []string{})))
+	require.Equal(t, []string{"mkdir -p '/app' && tar -xf - -C '/app'"}, executor.scripts)
+	require.Equal(t, []string{"main.go"}, executor.files)
+
+	require.NoError(t, s.Sync(filepath.Join(dir, "libs"), "/libs", NewFilter([]string{}, []string{})))
+	require.Len(t, executor.scripts, 2, "Should sync each directory at least once")
+}
+
+func requireContent(t *testing.T, expected, path string) {
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(content))
+}
+
+func TestShellQuote(t *testing.T) {
+	require.Equal(t, `'/work dir/it'\''s'`, shellQuote("/work dir/it's"))
+}
+
+func TestTarPullRejectsPathsThroughSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warp-tar")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	outside, err := ioutil.TempDir("", "warp-tar-outside")
+	require.NoError(t, err)
+	defer os.RemoveAll(outside)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	tests := map[string][]tar.Header{
+		"absolute link":          {{Name: "out", Linkname: outside, Typeflag: tar.TypeSymlink}},
+		"escaping link":          {{Name: "gen/out", Linkname: "../../outside", Typeflag: tar.TypeSymlink}},
+		"entry through symlink":  {{Name: "out", Linkname: "gen", Typeflag: tar.TypeSymlink}, {Name: "out/.bashrc", Mode: 0644, Typeflag: tar.TypeReg}},
+		"entry through existing": {{Name: "existing/.bashrc", Mode: 0644, Typeflag: tar.TypeReg}},
+	}
+	require.NoError(t, os.Symlink(outside, "existing"))
+
+	for name, headers := range tests {
+		var archive bytes.Buffer
+		tw := tar.NewWriter(&archive)
+		for _, hdr := range headers {
+			hdr := hdr
+			hdr.ModTime = time.Now()
+			require.NoError(t, tw.WriteHeader(&hdr))
+		}
+		require.NoError(t, tw.Close())
+
+		executor := &fakeExecutor{output: archive.Bytes()}
+		s := NewTar(executor, ioutil.Discard, ioutil.Discard)
+		require.Error(t, s.Pull("/work-dir", ".", []string{"gen"}, RemoteWins), name)
+	}
+
+	files, err := ioutil.ReadDir(outside)
+	require.NoError(t, err)
+	require.Empty(t, files, "Should not write outside of the destination")
+}
@@ -0,0 +1,187 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher notifies when files need to be synchronized again
+type Watcher interface {
+	// Changes returns channel what receives a value every time when files have changed.
+	// Multiple changes get coalesced into a single notification.
+	Changes() <-chan struct{}
+	// Close stops the watching and closes the Changes channel
+	Close() error
+}
+
+// maxDelayFactor limits how many times the delay the notification can be postponed, so the files
+// get synced also when the changes never stop (e.g. a log file what is written constantly)
+const maxDelayFactor = 10
+
+type fsWatcher struct {
+	watcher *fsnotify.Watcher
+	root    string
+	filter  *Filter
+	delay   time.Duration
+	changes chan struct{}
+}
+
+// NewFSWatcher creates new Watcher what watches recursively the filesystem events (e.g. inotify in Linux)
+// under the root directory and notifies when files have changed. Notification is sent when there haven't
+// been any new events during the delay so bursts of changes (e.g. git checkout) trigger only single sync,
+// but at latest after maxDelayFactor times the delay from the first change.
+// Changes in the paths what the filter excludes are not watched.
+func NewFSWatcher(root string, delay time.Duration, filter *Filter) (Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &fsWatcher{
+		watcher: watcher,
+		root:    root,
+		filter:  filter,
+		delay:   delay,
+		changes: make(chan struct{}, 1),
+	}
+
+	if err := w.addRecursive(root); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	go w.run()
+	return w, nil
+}
+
+func (w *fsWatcher) Changes() <-chan struct{} {
+	return w.changes
+}
+
+func (w *fsWatcher) Close() error {
+	return w.watcher.Close()
+}
+
+// addRecursive adds watch to the directory and all of its subdirectories because
+// the underlying filesystem notifications are not recursive
+func (w *fsWatcher) addRecursive(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// File might have been removed while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if w.excluded(path, true) {
+			return filepath.SkipDir
+		}
+		return w.watcher.Add(path)
+	})
+}
+
+func (w *fsWatcher) excluded(path string, isDir bool) bool {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	return w.filter.Excluded(rel, isDir)
+}
+
+func (w *fsWatcher) run() {
+	defer close(w.changes)
+
+	var debounce, deadline <-chan time.Time
+	changed := func() {
+		debounce = time.After(w.delay)
+		if deadline == nil {
+			deadline = time.After(maxDelayFactor * w.delay)
+		}
+	}
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			info, err := os.Lstat(event.Name)
+			isDir := err == nil && info.IsDir()
+			if w.excluded(event.Name, isDir) {
+				continue
+			}
+			if event.Op&fsnotify.Create == fsnotify.Create && isDir {
+				w.addRecursive(event.Name)
+			}
+			changed()
+
+		case _, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			// We might have missed some events (e.g. queue overflow) so better to sync
+			changed()
+
+		case <-debounce:
+			debounce, deadline = nil, nil
+			notify(w.changes)
+
+		case <-deadline:
+			debounce, deadline = nil, nil
+			notify(w.changes)
+		}
+	}
+}
+
+type poller struct {
+	changes chan struct{}
+	stop    chan struct{}
+}
+
+// NewPoller creates new Watcher what notifies in every interval, regardless are there changes or not.
+// This can be used as a fallback when filesystem events are not available.
+func NewPoller(interval time.Duration) Watcher {
+	p := &poller{
+		changes: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(p.changes)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				notify(p.changes)
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+func (p *poller) Changes() <-chan struct{} {
+	return p.changes
+}
+
+func (p *poller) Close() error {
+	close(p.stop)
+	return nil
+}
+
+// notify sends notification to the channel without blocking if there's already pending notification
+func notify(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
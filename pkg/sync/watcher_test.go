@@ -0,0 +1,81 @@
+package sync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFSWatcherNotifiesChangesInNewDirectories(t *testing.T) {
+	root, err := ioutil.TempDir("", "warp-watcher")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	w, err := NewFSWatcher(root, 10*time.Millisecond, NewFilter([]string{}, []string{"ignored/"}))
+	require.NoError(t, err)
+	defer w.Close()
+
+	require.NoError(t, os.Mkdir(filepath.Join(root, "ignored"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "ignored", "file.txt"), []byte("foo"), 0644))
+	select {
+	case <-w.Changes():
+		t.Fatal("Should not notify about changes in excluded paths")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, os.Mkdir(filepath.Join(root, "sub"), 0755))
+	waitChange(t, w)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "sub", "file.txt"), []byte("foo"), 0644))
+	waitChange(t, w)
+}
+
+func TestFSWatcherNotifiesContinuousChanges(t *testing.T) {
+	root, err := ioutil.TempDir("", "warp-watcher")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	w, err := NewFSWatcher(root, 50*time.Millisecond, NewFilter([]string{}, []string{}))
+	require.NoError(t, err)
+	defer w.Close()
+
+	// Write faster than the delay, so only the max delay triggers the notification
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ioutil.WriteFile(filepath.Join(root, "file.log"), []byte(time.Now().String()), 0644)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-w.Changes():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Should notify while the changes continue")
+	}
+}
+
+func TestPollerNotifies(t *testing.T) {
+	p := NewPoller(10 * time.Millisecond)
+	waitChange(t, p)
+	require.NoError(t, p.Close())
+}
+
+func waitChange(t *testing.T, w Watcher) {
+	select {
+	case <-w.Changes():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout while waiting change notification")
+	}
+}